@@ -0,0 +1,165 @@
+// import_course 从教务系统导出的课程文件导入课程，同时记录课程在哪个学年期开课
+//
+//	go run ./cmd/import_course --config config/dev.yaml --file courses.csv
+//
+// CSV 格式，不带表头，每行依次是课程号、课程名、老师、学院、课程性质、学分、学年、学期，
+// 学年期可以留空，留空的行只导入课程:
+//
+//	45000001,程序设计,张三,计算机学院,专业主干课程,3,2023,1
+//
+// 还在选课的学年期只导入课程，不记录开课，选课结束之前课程还可能被取消
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/ioc"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type row struct {
+	course   dao.Course
+	semester domain.Semester
+}
+
+func main() {
+	cfile := pflag.String("config", "config/config.yaml", "配置文件路径")
+	file := pflag.String("file", "", "课程文件路径，CSV 格式")
+	pflag.Parse()
+
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(*cfile)
+	err := viper.ReadInConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	rows, err := readRows(*file)
+	if err != nil {
+		panic(err)
+	}
+	db := ioc.InitDB(logger.NewNopLogger())
+	courseDAO := dao.NewGORMCourseDAO(db)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer cancel()
+
+	courses := make([]dao.Course, 0, len(rows))
+	for _, r := range rows {
+		courses = append(courses, r.course)
+	}
+	unknown, err := courseDAO.BatchUpsert(ctx, courses)
+	if err != nil {
+		panic(err)
+	}
+	if len(unknown) > 0 {
+		fmt.Printf("以下学院名没有对应的学院，需要补上别名: %s\n", strings.Join(unknown, "，"))
+	}
+
+	selecting, err := selectingSemesters(ctx, dao.NewGORMCalendarDAO(db))
+	if err != nil {
+		panic(err)
+	}
+	var (
+		offerings []dao.CourseOffering
+		skipped   int
+	)
+	for _, r := range rows {
+		if r.semester.IsZero() {
+			continue
+		}
+		if _, ok := selecting[r.semester]; ok {
+			skipped++
+			continue
+		}
+		// 唯一索引上的三个字段不会被改写，按原样查 id
+		id, er := courseDAO.FindIdByCourse(ctx, r.course)
+		if er != nil {
+			panic(er)
+		}
+		offerings = append(offerings, dao.CourseOffering{
+			CourseId: id,
+			Year:     r.semester.YearStr(),
+			Term:     r.semester.TermStr(),
+		})
+	}
+	err = dao.NewGORMCourseOfferingDAO(db).BatchUpsert(ctx, offerings)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("导入成功: %d 门课程，%d 条开课记录，%d 条还在选课的没有记录开课\n",
+		len(courses), len(offerings), skipped)
+}
+
+// selectingSemesters 现在处于选课窗口的学年期
+func selectingSemesters(ctx context.Context, calendarDAO dao.CalendarDAO) (map[domain.Semester]struct{}, error) {
+	terms, err := calendarDAO.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := make(map[domain.Semester]struct{})
+	for _, t := range terms {
+		semester, er := domain.ParseSemester(t.Year, t.Term)
+		if er != nil {
+			return nil, er
+		}
+		term := domain.AcademicTerm{
+			SelectionStartTime: time.UnixMilli(t.SelectionStartTime),
+			SelectionEndTime:   time.UnixMilli(t.SelectionEndTime),
+		}
+		if term.Selecting(now) {
+			res[semester] = struct{}{}
+		}
+	}
+	return res, nil
+}
+
+func readRows(file string) ([]row, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = 8
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]row, 0, len(records))
+	for i, rec := range records {
+		for j := range rec {
+			rec[j] = strings.TrimSpace(rec[j])
+		}
+		credit, err := strconv.ParseFloat(rec[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行学分不合法: %w", i+1, err)
+		}
+		semester, err := domain.ParseSemester(rec[6], rec[7])
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", i+1, err)
+		}
+		rows = append(rows, row{
+			course: dao.Course{
+				CourseCode: rec[0],
+				Name:       rec[1],
+				Teacher:    rec[2],
+				School:     rec[3],
+				Property:   int32(domain.CoursePropertyFromStr(rec[4])),
+				Credit:     credit,
+			},
+			semester: semester,
+		})
+	}
+	return rows, nil
+}
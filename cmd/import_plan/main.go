@@ -0,0 +1,172 @@
+// import_plan 从文件导入一个专业的培养方案，同专业同入学年份的培养方案会被整个覆盖
+//
+//	go run ./cmd/import_plan --config config/dev.yaml --file plan.json
+//
+// JSON 格式:
+//
+//	{"major": "计算机科学与技术", "entryYear": 2023, "totalCredits": 150,
+//	 "categories": [{"property": "通识核心课", "minCredits": 8}],
+//	 "requiredCourses": [{"courseCode": "45000001", "name": "程序设计"}]}
+//
+// CSV 格式，每行第一列是行的类型:
+//
+//	major,计算机科学与技术
+//	entryYear,2023
+//	totalCredits,150
+//	category,通识核心课,8
+//	course,45000001,程序设计
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/ioc"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	cfile := pflag.String("config", "config/config.yaml", "配置文件路径")
+	file := pflag.String("file", "", "培养方案文件路径，支持 .json 和 .csv")
+	pflag.Parse()
+
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(*cfile)
+	err := viper.ReadInConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	plan, err := readPlan(*file)
+	if err != nil {
+		panic(err)
+	}
+	if err = plan.Validate(); err != nil {
+		panic(err)
+	}
+	repo := repository.NewProgramPlanRepository(dao.NewGORMProgramPlanDAO(ioc.InitDB(logger.NewNopLogger())))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = repo.Save(ctx, plan); err != nil {
+		panic(err)
+	}
+	fmt.Printf("导入成功: %s %d级，%d 类学分要求，%d 门必修课\n",
+		plan.Major, plan.EntryYear, len(plan.Categories), len(plan.RequiredCourses))
+}
+
+func readPlan(file string) (domain.ProgramPlan, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return domain.ProgramPlan{}, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return readJSON(f)
+	case ".csv":
+		return readCSV(f)
+	default:
+		return domain.ProgramPlan{}, fmt.Errorf("不支持的文件格式: %s", file)
+	}
+}
+
+type planJSON struct {
+	Major        string  `json:"major"`
+	EntryYear    int     `json:"entryYear"`
+	TotalCredits float64 `json:"totalCredits"`
+	Categories   []struct {
+		Property   string  `json:"property"`
+		MinCredits float64 `json:"minCredits"`
+	} `json:"categories"`
+	RequiredCourses []struct {
+		CourseCode string `json:"courseCode"`
+		Name       string `json:"name"`
+	} `json:"requiredCourses"`
+}
+
+func readJSON(r io.Reader) (domain.ProgramPlan, error) {
+	var p planJSON
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return domain.ProgramPlan{}, err
+	}
+	plan := domain.ProgramPlan{
+		Major:        strings.TrimSpace(p.Major),
+		EntryYear:    p.EntryYear,
+		TotalCredits: p.TotalCredits,
+	}
+	for _, c := range p.Categories {
+		plan.Categories = append(plan.Categories, domain.CategoryRequirement{
+			Property:   domain.CoursePropertyFromStr(strings.TrimSpace(c.Property)),
+			MinCredits: c.MinCredits,
+		})
+	}
+	for _, c := range p.RequiredCourses {
+		plan.RequiredCourses = append(plan.RequiredCourses, domain.RequiredCourse{
+			CourseCode: strings.TrimSpace(c.CourseCode),
+			Name:       strings.TrimSpace(c.Name),
+		})
+	}
+	return plan, nil
+}
+
+func readCSV(r io.Reader) (domain.ProgramPlan, error) {
+	cr := csv.NewReader(r)
+	// 不同类型的行列数不一样
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return domain.ProgramPlan{}, err
+	}
+	var plan domain.ProgramPlan
+	for i, rec := range records {
+		if len(rec) < 2 {
+			return domain.ProgramPlan{}, fmt.Errorf("第 %d 行列数不对", i+1)
+		}
+		switch rec[0] {
+		case "major":
+			plan.Major = strings.TrimSpace(rec[1])
+		case "entryYear":
+			plan.EntryYear, err = strconv.Atoi(rec[1])
+		case "totalCredits":
+			plan.TotalCredits, err = strconv.ParseFloat(rec[1], 64)
+		case "category":
+			if len(rec) < 3 {
+				return domain.ProgramPlan{}, fmt.Errorf("第 %d 行缺少最低学分", i+1)
+			}
+			var minCredits float64
+			minCredits, err = strconv.ParseFloat(rec[2], 64)
+			plan.Categories = append(plan.Categories, domain.CategoryRequirement{
+				Property:   domain.CoursePropertyFromStr(strings.TrimSpace(rec[1])),
+				MinCredits: minCredits,
+			})
+		case "course":
+			var name string
+			if len(rec) >= 3 {
+				name = strings.TrimSpace(rec[2])
+			}
+			plan.RequiredCourses = append(plan.RequiredCourses, domain.RequiredCourse{
+				CourseCode: strings.TrimSpace(rec[1]),
+				Name:       name,
+			})
+		default:
+			return domain.ProgramPlan{}, fmt.Errorf("第 %d 行类型未知: %s", i+1, rec[0])
+		}
+		if err != nil {
+			return domain.ProgramPlan{}, fmt.Errorf("第 %d 行: %w", i+1, err)
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// AcademicTerm 一个学年期的校历
+type AcademicTerm struct {
+	Semester Semester
+	// 上课的起止时间
+	StartTime time.Time
+	EndTime   time.Time
+	// 选课窗口的起止时间，选课窗口一般在上课开始之前
+	SelectionStartTime time.Time
+	SelectionEndTime   time.Time
+}
+
+// OpenTime 这个学年期开始生效的时间，从选课开始就算进入这个学年期了
+func (t AcademicTerm) OpenTime() time.Time {
+	if t.SelectionStartTime.Before(t.StartTime) {
+		return t.SelectionStartTime
+	}
+	return t.StartTime
+}
+
+// Selecting 在 now 这个时间点，是否处于这个学年期的选课窗口
+func (t AcademicTerm) Selecting(now time.Time) bool {
+	return !now.Before(t.SelectionStartTime) && now.Before(t.SelectionEndTime)
+}
+
+// WeekdayDate 第 week 周星期 weekday（1 到 7）那天在 loc 时区的零点，StartTime 所在的那一周是第一周
+func (t AcademicTerm) WeekdayDate(week int, weekday int, loc *time.Location) time.Time {
+	start := t.StartTime.In(loc)
+	// 周日是 0，换成 7，往前推到周一
+	offset := (int(start.Weekday()) + 6) % 7
+	monday := time.Date(start.Year(), start.Month(), start.Day()-offset, 0, 0, 0, 0, loc)
+	return monday.AddDate(0, 0, (week-1)*7+weekday-1)
+}
+
+// CurrentTerm 当前所处的学年期和是否选课中
+type CurrentTerm struct {
+	Semester  Semester
+	Selecting bool
+}
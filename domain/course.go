@@ -1,12 +1,20 @@
 package domain
 
-import coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+import (
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+	"time"
+)
 
 type CourseSubscription struct {
-	Course Course
-	Uid    int64
-	Year   string
-	Term   string
+	Course   Course
+	Uid      int64
+	Semester Semester
+	// Grade 只有从成绩接口查到的历史课程才有，为 nil 表示没有成绩
+	Grade *SubscriptionGrade
+	// Sessions 教务系统返回的上课安排，只在查课程列表时带着，跟着修读记录单独存
+	Sessions []MeetingSession
+	// Utime 最后一次从教务系统确认这条修读记录的时间
+	Utime time.Time
 }
 
 type Course struct {
@@ -17,6 +25,84 @@ type Course struct {
 	School     string
 	Property   coursev1.CourseProperty
 	Credit     float64
+	// DepartmentId 入库时按 School 关联的学院，从教务系统刚拿到的课程为 0
+	DepartmentId int64
+}
+
+// CourseSibling 和某门课课程号或课程名相同的其他课，一般是不同老师开的同一门课
+type CourseSibling struct {
+	Course          Course
+	SameCode        bool
+	SameName        bool
+	SubscriberCount int64
+}
+
+// SubscriberStats 一门课的修读人数，BySemester 按学年期升序，方便画趋势
+// 同一个人重修会在两个学年期各算一次，但 Total 里只算一次
+type SubscriberStats struct {
+	Total      int64
+	BySemester []SemesterSubscriberCount
+}
+
+type SemesterSubscriberCount struct {
+	Semester Semester
+	Count    int64
+}
+
+// CreditTally Earned 是已经修完的学分，挂科的不算，InProgress 是当前及以后学年期在修的学分
+type CreditTally struct {
+	Earned      float64
+	InProgress  float64
+	CourseCount int64
+}
+
+func (t *CreditTally) Add(credit float64, inProgress bool) {
+	t.CourseCount++
+	if inProgress {
+		t.InProgress += credit
+	} else {
+		t.Earned += credit
+	}
+}
+
+type PropertyCredits struct {
+	Property coursev1.CourseProperty
+	Tally    CreditTally
+}
+
+type SemesterCredits struct {
+	Semester Semester
+	Tally    CreditTally
+}
+
+// CreditSummary 一个用户的学分汇总，ByProperty 按课程性质升序，BySemester 按学年期升序
+type CreditSummary struct {
+	Total      CreditTally
+	ByProperty []PropertyCredits
+	BySemester []SemesterCredits
+}
+
+// CourseFilter 课程列表的筛选条件，零值的字段表示不按该字段筛选
+type CourseFilter struct {
+	School       string
+	DepartmentId int64
+	Property     coursev1.CourseProperty
+	// Teacher 单个老师的名字，多个老师的课程只要有一个对上就算
+	Teacher   string
+	MinCredit float64
+	MaxCredit float64
+}
+
+// CourseSearchHit 搜索结果，Score 是相关度，同时作为下一页的游标，只能近似地翻页
+type CourseSearchHit struct {
+	Course Course
+	Score  float64
+}
+
+// CourseSuggestion 输入联想的结果，课程名和老师名分开返回，方便前端分组展示
+type CourseSuggestion struct {
+	Names    []string
+	Teachers []string
 }
 
 // CoursePropertyFromStr 将外部调用(ccnu调用)获取到的字符串课程转换为enum CourseProperty
@@ -0,0 +1,38 @@
+package domain
+
+import coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+
+// PlannedCourse 选课期间用户打算选的课，和真正选上的 CourseSubscription 分开存
+type PlannedCourse struct {
+	Course Course
+	// Status 选课结束之前都是 PlanStatusPlanned，结束之后按修读记录对账
+	Status coursev1.PlanStatus
+}
+
+// CourseConflict 两门课各有一次上课安排撞了，Course 是先加入计划的那门
+type CourseConflict struct {
+	Course       Course
+	Other        Course
+	Session      MeetingSession
+	OtherSession MeetingSession
+}
+
+type PlannedCredits struct {
+	Property coursev1.CourseProperty
+	Credits  float64
+}
+
+// CoursePlan 用户某个学年期的选课计划
+type CoursePlan struct {
+	Semester Semester
+	Courses  []PlannedCourse
+	// Conflicts 选上的课按自己教学班的上课安排，没选上的按人数最多的教学班
+	Conflicts []CourseConflict
+	// Unknown 不知道上课时间的课，检测不了冲突，不能让用户以为它们没有冲突
+	Unknown      []Course
+	TotalCredits float64
+	ByProperty   []PlannedCredits
+	// Reconciled 选课结束之后为 true，这时 Unplanned 是没有计划但是选上了的课
+	Reconciled bool
+	Unplanned  []Course
+}
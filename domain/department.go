@@ -0,0 +1,15 @@
+package domain
+
+// Department 学院，Name 是规范的名称，教务系统里同一个学院可能有好几种写法，都记在 Aliases 里
+type Department struct {
+	Id      int64
+	Code    string
+	Name    string
+	Aliases []string
+}
+
+// DepartmentSummary 学院列表用的，带上开课数量
+type DepartmentSummary struct {
+	Department  Department
+	CourseCount int64
+}
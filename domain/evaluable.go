@@ -0,0 +1,14 @@
+package domain
+
+import coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+
+// Evaluability 用户能不能评价某门课，课评服务按这个做限制，不用自己再算学年期
+type Evaluability struct {
+	Reason coursev1.EvaluableReason
+	// Semester 修读这门课的学年期，重修过的话是最近一个能评价的学年期，都不能评价时是最近一次，没有修过时为零值
+	Semester Semester
+}
+
+func (e Evaluability) Eligible() bool {
+	return e.Reason == coursev1.EvaluableReason_EvaluableReasonEligible
+}
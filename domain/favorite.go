@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// CourseFavorite 用户收藏的课程，只表示感兴趣，和真正修过的 CourseSubscription 不是一回事
+type CourseFavorite struct {
+	Id     int64
+	Uid    int64
+	Course Course
+	Ctime  time.Time
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+	"math"
+	"time"
+)
+
+// Grade 一门课的成绩，都是百分制
+type Grade struct {
+	Regular float64 // 平时成绩
+	Final   float64 // 期末成绩
+	Total   float64 // 总评
+}
+
+// SubscriptionGrade 挂在用户修读记录上的成绩，带上来源和获取时间，成绩可能会被更正
+type SubscriptionGrade struct {
+	Grade     Grade
+	Source    coursev1.GradeSource
+	FetchedAt time.Time
+}
+
+const (
+	PassScore = 60
+	// GradeBucketCount 成绩分布的分段数：[0,60) [60,70) [70,80) [80,90) [90,100]
+	GradeBucketCount = 5
+)
+
+// GradeSummary 一组成绩的汇总，只保留计数和总和，这样不同的分组可以直接相加
+type GradeSummary struct {
+	Count     int64
+	Sum       float64
+	Histogram [GradeBucketCount]int64
+}
+
+func (s *GradeSummary) Add(o GradeSummary) {
+	s.Count += o.Count
+	s.Sum += o.Sum
+	for i := range s.Histogram {
+		s.Histogram[i] += o.Histogram[i]
+	}
+}
+
+func (s GradeSummary) Avg() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// PassRate 除了第一段，都是及格的
+func (s GradeSummary) PassRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Count-s.Histogram[0]) / float64(s.Count)
+}
+
+// HistogramShares 各分数段的人数占比
+func (s GradeSummary) HistogramShares() [GradeBucketCount]float64 {
+	var res [GradeBucketCount]float64
+	if s.Count == 0 {
+		return res
+	}
+	for i, c := range s.Histogram {
+		res[i] = float64(c) / float64(s.Count)
+	}
+	return res
+}
+
+// PublishedGradeSummary 对外展示的成绩汇总，均值保留一位小数，比例保留两位小数，分布只给比例不给人数
+type PublishedGradeSummary struct {
+	SampleSize int64
+	Avg        float64
+	PassRate   float64
+	Histogram  [GradeBucketCount]float64
+}
+
+func (s GradeSummary) Publish() PublishedGradeSummary {
+	res := PublishedGradeSummary{
+		SampleSize: s.Count,
+		Avg:        math.Round(s.Avg()*10) / 10,
+		PassRate:   math.Round(s.PassRate()*100) / 100,
+	}
+	for i, share := range s.HistogramShares() {
+		res.Histogram[i] = math.Round(share*100) / 100
+	}
+	return res
+}
+
+// SemesterGradeSummary 某门课某个学年期的成绩汇总
+type SemesterGradeSummary struct {
+	CourseId int64
+	Semester Semester
+	Summary  GradeSummary
+}
+
+// TeacherGradeSummary 同一门课（课程号和课程名都相同）不同老师的成绩汇总
+type TeacherGradeSummary struct {
+	CourseId int64
+	Teacher  string
+	Summary  GradeSummary
+}
+
+// CourseGradeStats 课程的成绩统计，里面的汇总都来自攒够一批变动才更新的快照，样本量不足的分组不会出现在结果里，
+// 整体样本量不足时 Overall 为零值
+type CourseGradeStats struct {
+	Overall    GradeSummary
+	BySemester []SemesterGradeSummary
+	ByTeacher  []TeacherGradeSummary
+}
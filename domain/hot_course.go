@@ -0,0 +1,15 @@
+package domain
+
+import coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+
+// HotCourse 某个学年期修读人数排行里的一门课
+type HotCourse struct {
+	Course Course
+	Count  int64
+}
+
+// HotCourseFilter 零值的字段表示不按该字段筛选
+type HotCourseFilter struct {
+	DepartmentId int64
+	Property     coursev1.CourseProperty
+}
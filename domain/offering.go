@@ -0,0 +1,67 @@
+package domain
+
+const (
+	// MaxPeriod 一天最多的节次
+	MaxPeriod = 14
+	// MaxWeek 一个学期最多的教学周，DAO 里用 int64 的位存上课周，不能超过 63
+	MaxWeek = 25
+)
+
+// CourseOffering 某门课（具体到老师）在某个学年期开过
+// 上课安排不在这里，同一门课的不同教学班上课时间不一样，跟着修读记录存，见 CourseSubscription.Sessions
+type CourseOffering struct {
+	Course   Course
+	Semester Semester
+}
+
+// MeetingSession 一次上课安排，比如第 1-16 周的周一 3-4 节
+type MeetingSession struct {
+	// Weekday 1 到 7 表示周一到周日
+	Weekday     int
+	StartPeriod int
+	EndPeriod   int
+	// Weeks 上课的教学周，升序，单双周、跳周都直接体现在这里
+	Weeks     []int
+	Classroom string
+}
+
+func (s MeetingSession) Valid() bool {
+	if s.Weekday < 1 || s.Weekday > 7 || s.StartPeriod < 1 || s.StartPeriod > s.EndPeriod || s.EndPeriod > MaxPeriod {
+		return false
+	}
+	if len(s.Weeks) == 0 {
+		return false
+	}
+	for _, w := range s.Weeks {
+		if w < 1 || w > MaxWeek {
+			return false
+		}
+	}
+	return true
+}
+
+// WeeksMask 第 i 位表示第 i 周是否上课
+func (s MeetingSession) WeeksMask() int64 {
+	var mask int64
+	for _, w := range s.Weeks {
+		mask |= 1 << w
+	}
+	return mask
+}
+
+func WeeksFromMask(mask int64) []int {
+	var weeks []int
+	for w := 1; w <= MaxWeek; w++ {
+		if mask&(1<<w) != 0 {
+			weeks = append(weeks, w)
+		}
+	}
+	return weeks
+}
+
+// Overlaps 两次上课安排在同一周的同一天有重叠的节次
+func (s MeetingSession) Overlaps(o MeetingSession) bool {
+	return s.Weekday == o.Weekday &&
+		s.StartPeriod <= o.EndPeriod && o.StartPeriod <= s.EndPeriod &&
+		s.WeeksMask()&o.WeeksMask() != 0
+}
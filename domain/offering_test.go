@@ -0,0 +1,60 @@
+package domain
+
+import "testing"
+
+func TestMeetingSessionOverlaps(t *testing.T) {
+	session := func(weekday, start, end int, weeks ...int) MeetingSession {
+		return MeetingSession{Weekday: weekday, StartPeriod: start, EndPeriod: end, Weeks: weeks}
+	}
+	testCases := []struct {
+		name string
+		a    MeetingSession
+		b    MeetingSession
+		want bool
+	}{
+		{name: "完全一样", a: session(1, 3, 4, 1, 2, 3), b: session(1, 3, 4, 1, 2, 3), want: true},
+		{name: "不同的天", a: session(1, 3, 4, 1, 2, 3), b: session(2, 3, 4, 1, 2, 3), want: false},
+		{name: "节次部分重叠", a: session(1, 1, 3, 1), b: session(1, 3, 4, 1), want: true},
+		{name: "节次首尾相接不算", a: session(1, 1, 2, 1), b: session(1, 3, 4, 1), want: false},
+		{name: "一个包含另一个", a: session(1, 1, 4, 5), b: session(1, 2, 3, 5), want: true},
+		{name: "单双周错开", a: session(1, 3, 4, 1, 3, 5, 7), b: session(1, 3, 4, 2, 4, 6, 8), want: false},
+		{name: "只有一周撞了", a: session(1, 3, 4, 1, 2, 3, 4), b: session(1, 3, 4, 4, 5, 6), want: true},
+		{name: "前后半学期", a: session(3, 5, 6, 1, 2, 3, 4, 5, 6, 7, 8), b: session(3, 5, 6, 9, 10, 11, 12), want: false},
+		{name: "最后一周", a: session(5, 9, 10, MaxWeek), b: session(5, 10, 11, MaxWeek), want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.a.Overlaps(tc.b); got != tc.want {
+				t.Errorf("a.Overlaps(b) = %v, 期望 %v", got, tc.want)
+			}
+			if got := tc.b.Overlaps(tc.a); got != tc.want {
+				t.Errorf("b.Overlaps(a) = %v, 期望 %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWeeksMask(t *testing.T) {
+	testCases := []struct {
+		name  string
+		weeks []int
+	}{
+		{name: "连续", weeks: []int{1, 2, 3, 4}},
+		{name: "单周", weeks: []int{1, 3, 5, 7, 9}},
+		{name: "第一周和最后一周", weeks: []int{1, MaxWeek}},
+		{name: "没有", weeks: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := WeeksFromMask(MeetingSession{Weeks: tc.weeks}.WeeksMask())
+			if len(got) != len(tc.weeks) {
+				t.Fatalf("WeeksFromMask = %v, 期望 %v", got, tc.weeks)
+			}
+			for i := range got {
+				if got[i] != tc.weeks[i] {
+					t.Fatalf("WeeksFromMask = %v, 期望 %v", got, tc.weeks)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+)
+
+var ErrInvalidProgramPlan = errors.New("培养方案不合法")
+
+// ProgramPlan 培养方案，按专业和入学年份区分
+type ProgramPlan struct {
+	Id        int64
+	Major     string
+	EntryYear int
+	// TotalCredits 毕业要求的总学分
+	TotalCredits    float64
+	Categories      []CategoryRequirement
+	RequiredCourses []RequiredCourse
+}
+
+// CategoryRequirement 某一类课程性质的最低学分
+type CategoryRequirement struct {
+	Property   coursev1.CourseProperty
+	MinCredits float64
+}
+
+// RequiredCourse 必修的课程，按课程号匹配，课程名只是给人看的
+type RequiredCourse struct {
+	CourseCode string
+	Name       string
+}
+
+func (p ProgramPlan) Validate() error {
+	if p.Major == "" || p.EntryYear < minYear || p.EntryYear > maxYear || p.TotalCredits < 0 {
+		return fmt.Errorf("%w: major=%q entryYear=%d", ErrInvalidProgramPlan, p.Major, p.EntryYear)
+	}
+	seen := make(map[coursev1.CourseProperty]struct{}, len(p.Categories))
+	for _, c := range p.Categories {
+		if _, ok := seen[c.Property]; ok || c.Property == coursev1.CourseProperty_CoursePropertyUnknown || c.MinCredits <= 0 {
+			return fmt.Errorf("%w: category=%s", ErrInvalidProgramPlan, c.Property)
+		}
+		seen[c.Property] = struct{}{}
+	}
+	codes := make(map[string]struct{}, len(p.RequiredCourses))
+	for _, c := range p.RequiredCourses {
+		if _, ok := codes[c.CourseCode]; ok || c.CourseCode == "" {
+			return fmt.Errorf("%w: courseCode=%q", ErrInvalidProgramPlan, c.CourseCode)
+		}
+		codes[c.CourseCode] = struct{}{}
+	}
+	return nil
+}
+
+// CreditProgress 某项学分要求的完成情况，在修的学分不算完成
+type CreditProgress struct {
+	MinCredits float64
+	Earned     float64
+	InProgress float64
+}
+
+func (p CreditProgress) Satisfied() bool {
+	return p.Earned >= p.MinCredits
+}
+
+// Missing 还差的学分，在修的课全部通过之后还差多少
+func (p CreditProgress) Missing() float64 {
+	return max(0, p.MinCredits-p.Earned-p.InProgress)
+}
+
+type CategoryAudit struct {
+	Property coursev1.CourseProperty
+	Progress CreditProgress
+}
+
+type RequiredCourseAudit struct {
+	Course RequiredCourse
+	Status coursev1.RequirementStatus
+}
+
+// GraduationAudit 用户的修读记录对照培养方案的结果
+type GraduationAudit struct {
+	Plan            ProgramPlan
+	Total           CreditProgress
+	Categories      []CategoryAudit
+	RequiredCourses []RequiredCourseAudit
+}
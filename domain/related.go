@@ -0,0 +1,14 @@
+package domain
+
+// RelatedCourse 修过某门课的人也修过的课，Score 是两门课修读人群的余弦相似度
+type RelatedCourse struct {
+	Course Course
+	Score  float64
+}
+
+// CoSubscription 同时修过 CourseId 和 RelatedId 的人数
+type CoSubscription struct {
+	CourseId  int64
+	RelatedId int64
+	Count     int64
+}
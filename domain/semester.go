@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrInvalidSemester = errors.New("学年期不合法")
+
+const (
+	minTerm = 1
+	maxTerm = 3 // 第三学期是小学期
+	// 合理的学年范围，超出范围的大概率是传错了
+	minYear = 2000
+	maxYear = 2100
+)
+
+// Semester 学年期，Year 是学年的起始年份，如 2023 表示 2023-2024 学年，Term 是学期
+// 零值表示不限定学年期，也就是“全部”
+type Semester struct {
+	Year int
+	Term int
+}
+
+// ParseSemester 解析教务系统格式的学年期，year 和 term 都为空表示“全部”，只有一个为空是不合法的
+func ParseSemester(year string, term string) (Semester, error) {
+	if year == "" && term == "" {
+		return Semester{}, nil
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil || y < minYear || y > maxYear {
+		return Semester{}, fmt.Errorf("%w: year=%q", ErrInvalidSemester, year)
+	}
+	t, err := strconv.Atoi(term)
+	if err != nil || t < minTerm || t > maxTerm {
+		return Semester{}, fmt.Errorf("%w: term=%q", ErrInvalidSemester, term)
+	}
+	return Semester{Year: y, Term: t}, nil
+}
+
+func (s Semester) IsZero() bool {
+	return s == Semester{}
+}
+
+// YearStr 教务系统格式的学年，零值返回空串
+func (s Semester) YearStr() string {
+	if s.IsZero() {
+		return ""
+	}
+	return strconv.Itoa(s.Year)
+}
+
+// TermStr 教务系统格式的学期，零值返回空串
+func (s Semester) TermStr() string {
+	if s.IsZero() {
+		return ""
+	}
+	return strconv.Itoa(s.Term)
+}
+
+func (s Semester) String() string {
+	if s.IsZero() {
+		return "all"
+	}
+	return fmt.Sprintf("%d-%d", s.Year, s.Term)
+}
+
+// Compare s 在 o 之前返回 -1，相同返回 0，之后返回 1
+func (s Semester) Compare(o Semester) int {
+	switch {
+	case s.Year != o.Year:
+		if s.Year < o.Year {
+			return -1
+		}
+		return 1
+	case s.Term != o.Term:
+		if s.Term < o.Term {
+			return -1
+		}
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s Semester) Before(o Semester) bool {
+	return s.Compare(o) < 0
+}
+
+func (s Semester) After(o Semester) bool {
+	return s.Compare(o) > 0
+}
+
+func (s Semester) Next() Semester {
+	if s.Term >= maxTerm {
+		return Semester{Year: s.Year + 1, Term: minTerm}
+	}
+	return Semester{Year: s.Year, Term: s.Term + 1}
+}
+
+func (s Semester) Prev() Semester {
+	if s.Term <= minTerm {
+		return Semester{Year: s.Year - 1, Term: maxTerm}
+	}
+	return Semester{Year: s.Year, Term: s.Term - 1}
+}
+
+// IsHistory 相对于当前学年期 current 是否是历史学年期，“全部”也算历史，因为绝大部分都是历史的课
+func (s Semester) IsHistory(current Semester) bool {
+	return s.IsZero() || s.Before(current)
+}
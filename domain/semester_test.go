@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSemester(t *testing.T) {
+	testCases := []struct {
+		name    string
+		year    string
+		term    string
+		want    Semester
+		wantErr error
+	}{
+		{name: "正常", year: "2023", term: "1", want: Semester{Year: 2023, Term: 1}},
+		{name: "小学期", year: "2023", term: "3", want: Semester{Year: 2023, Term: 3}},
+		{name: "都为空表示全部", year: "", term: "", want: Semester{}},
+		{name: "只有学年", year: "2023", term: "", wantErr: ErrInvalidSemester},
+		{name: "只有学期", year: "", term: "1", wantErr: ErrInvalidSemester},
+		{name: "学期超出范围", year: "2023", term: "4", wantErr: ErrInvalidSemester},
+		{name: "学期为 0", year: "2023", term: "0", wantErr: ErrInvalidSemester},
+		{name: "学年超出范围", year: "1999", term: "1", wantErr: ErrInvalidSemester},
+		{name: "学年不是数字", year: "2023-2024", term: "1", wantErr: ErrInvalidSemester},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSemester(tc.year, tc.term)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, 期望 %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseSemester(%q, %q) = %v, 期望 %v", tc.year, tc.term, got, tc.want)
+			}
+			if tc.wantErr == nil && (got.YearStr() != tc.year || got.TermStr() != tc.term) {
+				t.Errorf("转回字符串 = %q %q, 期望 %q %q", got.YearStr(), got.TermStr(), tc.year, tc.term)
+			}
+		})
+	}
+}
+
+func TestSemesterCompare(t *testing.T) {
+	testCases := []struct {
+		name string
+		s    Semester
+		o    Semester
+		want int
+	}{
+		{name: "同一个学年期", s: Semester{Year: 2023, Term: 2}, o: Semester{Year: 2023, Term: 2}, want: 0},
+		{name: "同一学年前一个学期", s: Semester{Year: 2023, Term: 1}, o: Semester{Year: 2023, Term: 2}, want: -1},
+		{name: "小学期在第二学期之后", s: Semester{Year: 2023, Term: 3}, o: Semester{Year: 2023, Term: 2}, want: 1},
+		{name: "学年优先于学期", s: Semester{Year: 2022, Term: 3}, o: Semester{Year: 2023, Term: 1}, want: -1},
+		{name: "全部在所有学年期之前", s: Semester{}, o: Semester{Year: 2023, Term: 1}, want: -1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.s.Compare(tc.o); got != tc.want {
+				t.Errorf("%v.Compare(%v) = %d, 期望 %d", tc.s, tc.o, got, tc.want)
+			}
+			if got := tc.o.Compare(tc.s); got != -tc.want {
+				t.Errorf("%v.Compare(%v) = %d, 期望 %d", tc.o, tc.s, got, -tc.want)
+			}
+			if tc.s.Before(tc.o) != (tc.want < 0) || tc.s.After(tc.o) != (tc.want > 0) {
+				t.Errorf("Before/After 和 Compare 不一致: %v %v", tc.s, tc.o)
+			}
+		})
+	}
+}
+
+func TestSemesterNextPrev(t *testing.T) {
+	testCases := []struct {
+		name string
+		s    Semester
+		next Semester
+	}{
+		{name: "第一学期", s: Semester{Year: 2023, Term: 1}, next: Semester{Year: 2023, Term: 2}},
+		{name: "第二学期之后是小学期", s: Semester{Year: 2023, Term: 2}, next: Semester{Year: 2023, Term: 3}},
+		{name: "小学期之后是下一学年", s: Semester{Year: 2023, Term: 3}, next: Semester{Year: 2024, Term: 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.s.Next(); got != tc.next {
+				t.Errorf("%v.Next() = %v, 期望 %v", tc.s, got, tc.next)
+			}
+			if got := tc.next.Prev(); got != tc.s {
+				t.Errorf("%v.Prev() = %v, 期望 %v", tc.next, got, tc.s)
+			}
+		})
+	}
+}
+
+func TestSemesterIsHistory(t *testing.T) {
+	cur := Semester{Year: 2023, Term: 2}
+	testCases := []struct {
+		name string
+		s    Semester
+		want bool
+	}{
+		{name: "之前的学年期", s: Semester{Year: 2023, Term: 1}, want: true},
+		{name: "当前学年期", s: cur, want: false},
+		{name: "之后的学年期", s: Semester{Year: 2023, Term: 3}, want: false},
+		{name: "全部", s: Semester{}, want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.s.IsHistory(cur); got != tc.want {
+				t.Errorf("%v.IsHistory(%v) = %v, 期望 %v", tc.s, cur, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package domain
+
+// Teacher 教务系统里只有老师的名字，同名的老师目前没法区分
+type Teacher struct {
+	Id   int64
+	Name string
+}
+
+// TeacherCourse 老师教过的一门课，以及开课的学年期，最近的在前
+type TeacherCourse struct {
+	Course    Course
+	Semesters []Semester
+}
+
+type TeacherProfile struct {
+	Teacher Teacher
+	Courses []TeacherCourse
+}
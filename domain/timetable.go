@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// Timetable 用户某个学年期的课表，按周几和节次排好序，前端直接铺到周视图里
+type Timetable struct {
+	Semester Semester
+	Entries  []TimetableEntry
+	// Unscheduled 修读了但是不知道上课时间的课，不能就这么从课表里消失了
+	Unscheduled []Course
+}
+
+type TimetableEntry struct {
+	Course  Course
+	Session MeetingSession
+}
+
+// PeriodTime 一节课的上下课时间，相对于当天零点
+type PeriodTime struct {
+	Start time.Duration
+	End   time.Duration
+}
@@ -3,6 +3,7 @@ package event
 import (
 	"context"
 	"github.com/IBM/sarama"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
 	"github.com/MuxiKeStack/be-course/domain"
 	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/pkg/saramax"
@@ -12,14 +13,16 @@ import (
 )
 
 type CourseListEventConsumer struct {
-	client sarama.Client
-	l      logger.Logger
-	repo   repository.CourseSubscriptionRepository
+	client     sarama.Client
+	l          logger.Logger
+	repo       repository.CourseSubscriptionRepository
+	courseRepo repository.CourseRepository
+	hotRepo    repository.HotCourseRepository
 }
 
-func NewCourseListEventConsumer(client sarama.Client, l logger.Logger,
-	repo repository.CourseSubscriptionRepository) *CourseListEventConsumer {
-	return &CourseListEventConsumer{client: client, l: l, repo: repo}
+func NewCourseListEventConsumer(client sarama.Client, l logger.Logger, repo repository.CourseSubscriptionRepository,
+	courseRepo repository.CourseRepository, hotRepo repository.HotCourseRepository) *CourseListEventConsumer {
+	return &CourseListEventConsumer{client: client, l: l, repo: repo, courseRepo: courseRepo, hotRepo: hotRepo}
 }
 
 // Start 这边就是自己启动 goroutine 了
@@ -41,16 +44,73 @@ func (c *CourseListEventConsumer) Start() error {
 }
 
 func (c *CourseListEventConsumer) BatchConsume(msgs []*sarama.ConsumerMessage, events []CourseFromXkEvent) error {
-	courseSubscriptions := slice.Map(events, func(c int, src CourseFromXkEvent) domain.CourseSubscription {
-		return domain.CourseSubscription{
-			Course: domain.Course{Id: src.CourseId},
-			Uid:    src.Uid,
-			Year:   src.Year,
-			Term:   src.Term,
+	courseSubscriptions := slice.FilterMap(events, func(idx int, src CourseFromXkEvent) (domain.CourseSubscription, bool) {
+		semester, err := domain.ParseSemester(src.Year, src.Term)
+		if err != nil || semester.IsZero() {
+			// 生产者那边都是解析过的，走到这里说明消息本身有问题，重试也没用
+			c.l.Error("课程订阅事件的学年期不合法", logger.Error(err),
+				logger.Int64("uid", src.Uid), logger.Int64("courseId", src.CourseId))
+			return domain.CourseSubscription{}, false
 		}
+		cs := domain.CourseSubscription{
+			Course:   domain.Course{Id: src.CourseId},
+			Uid:      src.Uid,
+			Semester: semester,
+		}
+		if src.Grade != nil {
+			cs.Grade = &domain.SubscriptionGrade{
+				Grade: domain.Grade{
+					Regular: src.Grade.Regular,
+					Final:   src.Grade.Final,
+					Total:   src.Grade.Total,
+				},
+				Source:    coursev1.GradeSource(src.Grade.Source),
+				FetchedAt: time.UnixMilli(src.Grade.FetchedAt),
+			}
+		}
+		return cs, true
 	})
 	// 批量存储到数据库
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	return c.repo.BatchCreateCourseSubscription(ctx, courseSubscriptions)
+	inserted, err := c.repo.BatchCreateCourseSubscription(ctx, courseSubscriptions)
+	if err != nil {
+		return err
+	}
+	// 排行失败了不重试，重试的时候已经不是新增的了，等离线任务重建
+	err = c.incrHotCourses(ctx, inserted)
+	if err != nil {
+		c.l.Error("更新热门课程排行失败", logger.Error(err))
+	}
+	return nil
+}
+
+// incrHotCourses 只有新增的修读记录才算进排行，重复消费不会重复计数
+func (c *CourseListEventConsumer) incrHotCourses(ctx context.Context, inserted []domain.CourseSubscription) error {
+	if len(inserted) == 0 {
+		return nil
+	}
+	courses, err := c.courseRepo.FindByIds(ctx, slice.Map(inserted, func(idx int, src domain.CourseSubscription) int64 {
+		return src.Course.Id
+	}))
+	if err != nil {
+		return err
+	}
+	courseMap := make(map[int64]domain.Course, len(courses))
+	for _, cs := range courses {
+		courseMap[cs.Id] = cs
+	}
+	bySemester := make(map[domain.Semester][]domain.Course)
+	for _, cs := range inserted {
+		if course, ok := courseMap[cs.Course.Id]; ok {
+			bySemester[cs.Semester] = append(bySemester[cs.Semester], course)
+		}
+	}
+	for semester, cs := range bySemester {
+		err = c.hotRepo.BatchIncr(ctx, semester, cs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
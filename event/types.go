@@ -5,6 +5,16 @@ type CourseFromXkEvent struct {
 	Uid      int64
 	Year     string
 	Term     string
+	// Grade 历史课程的成绩，没有成绩时为 nil
+	Grade *GradeInfo
+}
+
+type GradeInfo struct {
+	Regular   float64
+	Final     float64
+	Total     float64
+	Source    int32
+	FetchedAt int64
 }
 
 func (e *CourseFromXkEvent) Topic() string {
@@ -2,16 +2,33 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
 	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/service"
 	"github.com/ecodeclub/ekit/slice"
 	"google.golang.org/grpc"
+	"strconv"
+	"time"
 )
 
 type CourseServiceServer struct {
 	coursev1.UnimplementedCourseServiceServer
-	svc service.CourseService
+	svc        service.CourseService
+	calendar   service.CalendarService
+	grade      service.GradeService
+	teacher    service.TeacherService
+	department service.DepartmentService
+	related    service.RelatedCourseService
+	hot        service.HotCourseService
+	plan       service.ProgramPlanService
+	timetable  service.TimetableService
+	coursePlan service.CoursePlanService
+	favorite   service.CourseFavoriteService
+	evaluable  service.EvaluableService
+	admin      service.AdminService
+	l          logger.Logger
 }
 
 func (s *CourseServiceServer) Subscribed(ctx context.Context, request *coursev1.SubscribedRequest) (*coursev1.SubscribedResponse, error) {
@@ -21,8 +38,15 @@ func (s *CourseServiceServer) Subscribed(ctx context.Context, request *coursev1.
 	}, err
 }
 
-func NewCourseServiceServer(svc service.CourseService) *CourseServiceServer {
-	return &CourseServiceServer{svc: svc}
+func NewCourseServiceServer(svc service.CourseService, calendar service.CalendarService,
+	grade service.GradeService, teacher service.TeacherService, department service.DepartmentService,
+	related service.RelatedCourseService, hot service.HotCourseService,
+	plan service.ProgramPlanService, timetable service.TimetableService,
+	coursePlan service.CoursePlanService, favorite service.CourseFavoriteService,
+	evaluable service.EvaluableService, admin service.AdminService, l logger.Logger) *CourseServiceServer {
+	return &CourseServiceServer{svc: svc, calendar: calendar, grade: grade, teacher: teacher, department: department,
+		related: related, hot: hot, plan: plan, timetable: timetable,
+		coursePlan: coursePlan, favorite: favorite, evaluable: evaluable, admin: admin, l: l}
 }
 
 func (s *CourseServiceServer) Register(server grpc.ServiceRegistrar) {
@@ -30,8 +54,12 @@ func (s *CourseServiceServer) Register(server grpc.ServiceRegistrar) {
 }
 
 func (s *CourseServiceServer) SubscriptionList(ctx context.Context, request *coursev1.SubscriptionListRequest) (*coursev1.SubscriptionListResponse, error) {
+	semester, err := domain.ParseSemester(request.GetYear(), request.GetTerm())
+	if err != nil {
+		return &coursev1.SubscriptionListResponse{}, err
+	}
 	css, err := s.svc.SubscriptionList(ctx, request.GetStudentId(), request.GetPassword(),
-		request.GetYear(), request.GetTerm(), request.GetUid()) // 传入了uid 说明这里肯定是调用带有容错或性能提升的List
+		semester, request.GetUid()) // 传入了uid 说明这里肯定是调用带有容错或性能提升的List
 	return &coursev1.SubscriptionListResponse{
 		CourseSubscriptions: slice.Map(css, func(idx int, src domain.CourseSubscription) *coursev1.CourseSubscription {
 			return convertToCourseSubscriptionV(src)
@@ -41,8 +69,26 @@ func (s *CourseServiceServer) SubscriptionList(ctx context.Context, request *cou
 
 func (s *CourseServiceServer) GetDetailById(ctx context.Context, request *coursev1.GetDetailByIdRequest) (*coursev1.GetDetailByIdResponse, error) {
 	c, err := s.svc.GetDetailById(ctx, request.GetCourseId())
+	if err != nil {
+		return &coursev1.GetDetailByIdResponse{}, err
+	}
+	// 成绩统计只是附带的，查不到也要把课程返回
+	stats, err := s.grade.GetCourseGradeStats(ctx, c.Id)
+	if err != nil {
+		s.l.Error("查询课程成绩统计失败", logger.Error(err), logger.Int64("courseId", c.Id))
+	}
 	return &coursev1.GetDetailByIdResponse{
-		Course: convertToCourseV(c),
+		Course:     convertToCourseV(c),
+		GradeStats: convertToGradeStatsV(stats),
+	}, nil
+}
+
+func (s *CourseServiceServer) GetDetailsByIds(ctx context.Context, request *coursev1.GetDetailsByIdsRequest) (*coursev1.GetDetailsByIdsResponse, error) {
+	cs, err := s.svc.GetDetailsByIds(ctx, request.GetCourseIds())
+	return &coursev1.GetDetailsByIdsResponse{
+		Courses: slice.Map(cs, func(idx int, src domain.Course) *coursev1.Course {
+			return convertToCourseV(src)
+		}),
 	}, err
 }
 
@@ -68,15 +114,430 @@ func (s *CourseServiceServer) FindIdsOrUpsertByCourses(ctx context.Context, requ
 	}, nil
 }
 
+func (s *CourseServiceServer) List(ctx context.Context, request *coursev1.ListRequest) (*coursev1.ListResponse, error) {
+	courses, err := s.svc.List(ctx, domain.CourseFilter{
+		School:       request.GetSchool(),
+		DepartmentId: request.GetDepartmentId(),
+		Property:     request.GetProperty(),
+		Teacher:      request.GetTeacher(),
+		MinCredit:    request.GetMinCredit(),
+		MaxCredit:    request.GetMaxCredit(),
+	}, request.GetCurId(), request.GetLimit())
+	return &coursev1.ListResponse{
+		Courses: slice.Map(courses, func(idx int, src domain.Course) *coursev1.Course {
+			return convertToCourseV(src)
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) Suggest(ctx context.Context, request *coursev1.SuggestRequest) (*coursev1.SuggestResponse, error) {
+	suggestion, err := s.svc.Suggest(ctx, request.GetPrefix(), request.GetLimit())
+	return &coursev1.SuggestResponse{
+		Names:    suggestion.Names,
+		Teachers: suggestion.Teachers,
+	}, err
+}
+
+func (s *CourseServiceServer) Search(ctx context.Context, request *coursev1.SearchRequest) (*coursev1.SearchResponse, error) {
+	hits, err := s.svc.Search(ctx, request.GetKeyword(), request.GetCurScore(), request.GetCurId(), request.GetLimit())
+	if err != nil {
+		return &coursev1.SearchResponse{}, err
+	}
+	res := &coursev1.SearchResponse{
+		Courses: slice.Map(hits, func(idx int, src domain.CourseSearchHit) *coursev1.Course {
+			return convertToCourseV(src.Course)
+		}),
+	}
+	if len(hits) > 0 {
+		// 下一页的游标
+		last := hits[len(hits)-1]
+		res.NextCurScore = last.Score
+		res.NextCurId = last.Course.Id
+	}
+	return res, nil
+}
+
+// UpsertAcademicTerm 管理接口，Uid 是操作人
+func (s *CourseServiceServer) UpsertAcademicTerm(ctx context.Context, request *coursev1.UpsertAcademicTermRequest) (*coursev1.UpsertAcademicTermResponse, error) {
+	if err := s.admin.CheckAdmin(ctx, request.GetUid()); err != nil {
+		return &coursev1.UpsertAcademicTermResponse{}, err
+	}
+	term, err := convertToAcademicTermDomain(request.GetTerm())
+	if err != nil {
+		return &coursev1.UpsertAcademicTermResponse{}, err
+	}
+	err = s.calendar.UpsertTerm(ctx, term)
+	return &coursev1.UpsertAcademicTermResponse{}, err
+}
+
+func (s *CourseServiceServer) ListAcademicTerms(ctx context.Context, request *coursev1.ListAcademicTermsRequest) (*coursev1.ListAcademicTermsResponse, error) {
+	terms, err := s.calendar.ListTerms(ctx)
+	return &coursev1.ListAcademicTermsResponse{
+		Terms: slice.Map(terms, func(idx int, src domain.AcademicTerm) *coursev1.AcademicTerm {
+			return convertToAcademicTermV(src)
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetCurrentTerm(ctx context.Context, request *coursev1.GetCurrentTermRequest) (*coursev1.GetCurrentTermResponse, error) {
+	cur := s.calendar.Current(ctx)
+	return &coursev1.GetCurrentTermResponse{
+		Year:      cur.Semester.YearStr(),
+		Term:      cur.Semester.TermStr(),
+		Selecting: cur.Selecting,
+	}, nil
+}
+
+func (s *CourseServiceServer) GetTeacherProfile(ctx context.Context, request *coursev1.GetTeacherProfileRequest) (*coursev1.GetTeacherProfileResponse, error) {
+	p, err := s.teacher.GetProfile(ctx, request.GetTeacherId())
+	return &coursev1.GetTeacherProfileResponse{
+		Teacher: convertToTeacherV(p.Teacher),
+		Courses: slice.Map(p.Courses, func(idx int, src domain.TeacherCourse) *coursev1.TeacherCourse {
+			return &coursev1.TeacherCourse{
+				Course: convertToCourseV(src.Course),
+				Semesters: slice.Map(src.Semesters, func(idx int, src domain.Semester) *coursev1.Semester {
+					return &coursev1.Semester{
+						Year: src.YearStr(),
+						Term: src.TermStr(),
+					}
+				}),
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) FindTeacherByName(ctx context.Context, request *coursev1.FindTeacherByNameRequest) (*coursev1.FindTeacherByNameResponse, error) {
+	t, err := s.teacher.FindByName(ctx, request.GetName())
+	return &coursev1.FindTeacherByNameResponse{
+		Teacher: convertToTeacherV(t),
+	}, err
+}
+
+func (s *CourseServiceServer) GetCourseOfferings(ctx context.Context, request *coursev1.GetCourseOfferingsRequest) (*coursev1.GetCourseOfferingsResponse, error) {
+	offerings, err := s.svc.GetOfferings(ctx, request.GetCourseId())
+	return &coursev1.GetCourseOfferingsResponse{
+		Offerings: slice.Map(offerings, func(idx int, src domain.CourseOffering) *coursev1.CourseOffering {
+			return &coursev1.CourseOffering{
+				Course: convertToCourseV(src.Course),
+				Year:   src.Semester.YearStr(),
+				Term:   src.Semester.TermStr(),
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetSiblings(ctx context.Context, request *coursev1.GetSiblingsRequest) (*coursev1.GetSiblingsResponse, error) {
+	siblings, err := s.svc.GetSiblings(ctx, request.GetCourseId())
+	return &coursev1.GetSiblingsResponse{
+		Siblings: slice.Map(siblings, func(idx int, src domain.CourseSibling) *coursev1.CourseSibling {
+			return &coursev1.CourseSibling{
+				Course:          convertToCourseV(src.Course),
+				SameCode:        src.SameCode,
+				SameName:        src.SameName,
+				SubscriberCount: src.SubscriberCount,
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetRelatedCourses(ctx context.Context, request *coursev1.GetRelatedCoursesRequest) (*coursev1.GetRelatedCoursesResponse, error) {
+	related, err := s.related.GetRelated(ctx, request.GetCourseId(), request.GetLimit())
+	return &coursev1.GetRelatedCoursesResponse{
+		Courses: slice.Map(related, func(idx int, src domain.RelatedCourse) *coursev1.RelatedCourse {
+			return &coursev1.RelatedCourse{
+				Course: convertToCourseV(src.Course),
+				Score:  src.Score,
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetHotCourses(ctx context.Context, request *coursev1.GetHotCoursesRequest) (*coursev1.GetHotCoursesResponse, error) {
+	semester, err := domain.ParseSemester(request.GetYear(), request.GetTerm())
+	if err != nil {
+		return &coursev1.GetHotCoursesResponse{}, err
+	}
+	hots, err := s.hot.Top(ctx, semester, domain.HotCourseFilter{
+		DepartmentId: request.GetDepartmentId(),
+		Property:     request.GetProperty(),
+	}, request.GetLimit())
+	return &coursev1.GetHotCoursesResponse{
+		Courses: slice.Map(hots, func(idx int, src domain.HotCourse) *coursev1.HotCourse {
+			return &coursev1.HotCourse{
+				Course:          convertToCourseV(src.Course),
+				SubscriberCount: src.Count,
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetSubscriberStats(ctx context.Context, request *coursev1.GetSubscriberStatsRequest) (*coursev1.GetSubscriberStatsResponse, error) {
+	stats, err := s.svc.GetSubscriberStats(ctx, request.GetCourseId())
+	return &coursev1.GetSubscriberStatsResponse{
+		Total: stats.Total,
+		BySemester: slice.Map(stats.BySemester, func(idx int, src domain.SemesterSubscriberCount) *coursev1.SemesterSubscriberCount {
+			return &coursev1.SemesterSubscriberCount{
+				Year:  src.Semester.YearStr(),
+				Term:  src.Semester.TermStr(),
+				Count: src.Count,
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetCreditSummary(ctx context.Context, request *coursev1.GetCreditSummaryRequest) (*coursev1.GetCreditSummaryResponse, error) {
+	summary, err := s.svc.GetCreditSummary(ctx, request.GetUid())
+	return &coursev1.GetCreditSummaryResponse{
+		Total: convertToCreditTallyV(summary.Total),
+		ByProperty: slice.Map(summary.ByProperty, func(idx int, src domain.PropertyCredits) *coursev1.PropertyCredits {
+			return &coursev1.PropertyCredits{
+				Property: src.Property,
+				Tally:    convertToCreditTallyV(src.Tally),
+			}
+		}),
+		BySemester: slice.Map(summary.BySemester, func(idx int, src domain.SemesterCredits) *coursev1.SemesterCredits {
+			return &coursev1.SemesterCredits{
+				Year:  src.Semester.YearStr(),
+				Term:  src.Semester.TermStr(),
+				Tally: convertToCreditTallyV(src.Tally),
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) AuditGraduation(ctx context.Context, request *coursev1.AuditGraduationRequest) (*coursev1.AuditGraduationResponse, error) {
+	entryYear, err := strconv.Atoi(request.GetEntryYear())
+	if err != nil {
+		return &coursev1.AuditGraduationResponse{}, fmt.Errorf("%w: entryYear=%q", domain.ErrInvalidProgramPlan, request.GetEntryYear())
+	}
+	audit, err := s.plan.Audit(ctx, request.GetUid(), request.GetMajor(), entryYear)
+	if err != nil {
+		return &coursev1.AuditGraduationResponse{}, err
+	}
+	return &coursev1.AuditGraduationResponse{
+		Total: convertToCreditProgressV(audit.Total),
+		Categories: slice.Map(audit.Categories, func(idx int, src domain.CategoryAudit) *coursev1.CategoryAudit {
+			return &coursev1.CategoryAudit{
+				Property: src.Property,
+				Progress: convertToCreditProgressV(src.Progress),
+			}
+		}),
+		RequiredCourses: slice.Map(audit.RequiredCourses, func(idx int, src domain.RequiredCourseAudit) *coursev1.RequiredCourseAudit {
+			return &coursev1.RequiredCourseAudit{
+				CourseCode: src.Course.CourseCode,
+				Name:       src.Course.Name,
+				Status:     src.Status,
+			}
+		}),
+	}, nil
+}
+
+func (s *CourseServiceServer) GetTimetable(ctx context.Context, request *coursev1.GetTimetableRequest) (*coursev1.GetTimetableResponse, error) {
+	semester, err := domain.ParseSemester(request.GetYear(), request.GetTerm())
+	if err != nil {
+		return &coursev1.GetTimetableResponse{}, err
+	}
+	tt, err := s.timetable.GetTimetable(ctx, request.GetUid(), semester)
+	return &coursev1.GetTimetableResponse{
+		Entries: slice.Map(tt.Entries, func(idx int, src domain.TimetableEntry) *coursev1.TimetableEntry {
+			return &coursev1.TimetableEntry{
+				Course:  convertToCourseV(src.Course),
+				Session: convertToMeetingSessionV(src.Session),
+			}
+		}),
+		Unscheduled: slice.Map(tt.Unscheduled, func(idx int, src domain.Course) *coursev1.Course {
+			return convertToCourseV(src)
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) ExportTimetableICS(ctx context.Context, request *coursev1.ExportTimetableICSRequest) (*coursev1.ExportTimetableICSResponse, error) {
+	semester, err := domain.ParseSemester(request.GetYear(), request.GetTerm())
+	if err != nil {
+		return &coursev1.ExportTimetableICSResponse{}, err
+	}
+	ics, err := s.timetable.ExportICS(ctx, request.GetUid(), semester)
+	return &coursev1.ExportTimetableICSResponse{
+		Ics: ics,
+	}, err
+}
+
+func (s *CourseServiceServer) AddPlannedCourse(ctx context.Context, request *coursev1.AddPlannedCourseRequest) (*coursev1.AddPlannedCourseResponse, error) {
+	err := s.coursePlan.Add(ctx, request.GetUid(), request.GetCourseId())
+	return &coursev1.AddPlannedCourseResponse{}, err
+}
+
+func (s *CourseServiceServer) RemovePlannedCourse(ctx context.Context, request *coursev1.RemovePlannedCourseRequest) (*coursev1.RemovePlannedCourseResponse, error) {
+	err := s.coursePlan.Remove(ctx, request.GetUid(), request.GetCourseId())
+	return &coursev1.RemovePlannedCourseResponse{}, err
+}
+
+func (s *CourseServiceServer) GetCoursePlan(ctx context.Context, request *coursev1.GetCoursePlanRequest) (*coursev1.GetCoursePlanResponse, error) {
+	semester, err := domain.ParseSemester(request.GetYear(), request.GetTerm())
+	if err != nil {
+		return &coursev1.GetCoursePlanResponse{}, err
+	}
+	plan, err := s.coursePlan.Get(ctx, request.GetUid(), semester)
+	return &coursev1.GetCoursePlanResponse{
+		Courses: slice.Map(plan.Courses, func(idx int, src domain.PlannedCourse) *coursev1.PlannedCourse {
+			return &coursev1.PlannedCourse{
+				Course: convertToCourseV(src.Course),
+				Status: src.Status,
+			}
+		}),
+		Conflicts: slice.Map(plan.Conflicts, func(idx int, src domain.CourseConflict) *coursev1.CourseConflict {
+			return &coursev1.CourseConflict{
+				CourseId:      src.Course.Id,
+				OtherCourseId: src.Other.Id,
+				Session:       convertToMeetingSessionV(src.Session),
+				OtherSession:  convertToMeetingSessionV(src.OtherSession),
+			}
+		}),
+		TotalCredits: plan.TotalCredits,
+		ByProperty: slice.Map(plan.ByProperty, func(idx int, src domain.PlannedCredits) *coursev1.PlannedCredits {
+			return &coursev1.PlannedCredits{
+				Property: src.Property,
+				Credits:  src.Credits,
+			}
+		}),
+		Reconciled: plan.Reconciled,
+		Unplanned: slice.Map(plan.Unplanned, func(idx int, src domain.Course) *coursev1.Course {
+			return convertToCourseV(src)
+		}),
+		Unknown: slice.Map(plan.Unknown, func(idx int, src domain.Course) *coursev1.Course {
+			return convertToCourseV(src)
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) AddFavorite(ctx context.Context, request *coursev1.AddFavoriteRequest) (*coursev1.AddFavoriteResponse, error) {
+	err := s.favorite.Add(ctx, request.GetUid(), request.GetCourseId())
+	return &coursev1.AddFavoriteResponse{}, err
+}
+
+func (s *CourseServiceServer) RemoveFavorite(ctx context.Context, request *coursev1.RemoveFavoriteRequest) (*coursev1.RemoveFavoriteResponse, error) {
+	err := s.favorite.Remove(ctx, request.GetUid(), request.GetCourseId())
+	return &coursev1.RemoveFavoriteResponse{}, err
+}
+
+func (s *CourseServiceServer) ListFavorites(ctx context.Context, request *coursev1.ListFavoritesRequest) (*coursev1.ListFavoritesResponse, error) {
+	fs, err := s.favorite.List(ctx, request.GetUid(), request.GetCurId(), request.GetLimit())
+	return &coursev1.ListFavoritesResponse{
+		Favorites: slice.Map(fs, func(idx int, src domain.CourseFavorite) *coursev1.CourseFavorite {
+			return &coursev1.CourseFavorite{
+				Id:     src.Id,
+				Course: convertToCourseV(src.Course),
+				Ctime:  src.Ctime.UnixMilli(),
+			}
+		}),
+	}, err
+}
+
+func (s *CourseServiceServer) GetFavoriteCount(ctx context.Context, request *coursev1.GetFavoriteCountRequest) (*coursev1.GetFavoriteCountResponse, error) {
+	cnt, err := s.favorite.Count(ctx, request.GetCourseId())
+	return &coursev1.GetFavoriteCountResponse{
+		Count: cnt,
+	}, err
+}
+
+func (s *CourseServiceServer) CheckEvaluable(ctx context.Context, request *coursev1.CheckEvaluableRequest) (*coursev1.CheckEvaluableResponse, error) {
+	e, err := s.evaluable.Check(ctx, request.GetUid(), request.GetCourseId())
+	return &coursev1.CheckEvaluableResponse{
+		Eligible: e.Eligible(),
+		Reason:   e.Reason,
+		Year:     e.Semester.YearStr(),
+		Term:     e.Semester.TermStr(),
+	}, err
+}
+
+func (s *CourseServiceServer) ListDepartments(ctx context.Context, request *coursev1.ListDepartmentsRequest) (*coursev1.ListDepartmentsResponse, error) {
+	ds, err := s.department.List(ctx)
+	return &coursev1.ListDepartmentsResponse{
+		Departments: slice.Map(ds, func(idx int, src domain.DepartmentSummary) *coursev1.Department {
+			return &coursev1.Department{
+				Id:          src.Department.Id,
+				Code:        src.Department.Code,
+				Name:        src.Department.Name,
+				Aliases:     src.Department.Aliases,
+				CourseCount: src.CourseCount,
+			}
+		}),
+	}, err
+}
+
+// SaveDepartment 管理接口，Uid 是操作人
+func (s *CourseServiceServer) SaveDepartment(ctx context.Context, request *coursev1.SaveDepartmentRequest) (*coursev1.SaveDepartmentResponse, error) {
+	if err := s.admin.CheckAdmin(ctx, request.GetUid()); err != nil {
+		return &coursev1.SaveDepartmentResponse{}, err
+	}
+	d := request.GetDepartment()
+	id, err := s.department.Save(ctx, domain.Department{
+		Id:      d.GetId(),
+		Code:    d.GetCode(),
+		Name:    d.GetName(),
+		Aliases: d.GetAliases(),
+	})
+	return &coursev1.SaveDepartmentResponse{
+		Id: id,
+	}, err
+}
+
+// MergeDepartments 管理接口，Uid 是操作人
+func (s *CourseServiceServer) MergeDepartments(ctx context.Context, request *coursev1.MergeDepartmentsRequest) (*coursev1.MergeDepartmentsResponse, error) {
+	if err := s.admin.CheckAdmin(ctx, request.GetUid()); err != nil {
+		return &coursev1.MergeDepartmentsResponse{}, err
+	}
+	err := s.department.Merge(ctx, request.GetFromId(), request.GetToId())
+	return &coursev1.MergeDepartmentsResponse{}, err
+}
+
+func convertToCreditTallyV(t domain.CreditTally) *coursev1.CreditTally {
+	return &coursev1.CreditTally{
+		Earned:      t.Earned,
+		InProgress:  t.InProgress,
+		CourseCount: t.CourseCount,
+	}
+}
+
+func convertToCreditProgressV(p domain.CreditProgress) *coursev1.CreditProgress {
+	return &coursev1.CreditProgress{
+		MinCredits: p.MinCredits,
+		Earned:     p.Earned,
+		InProgress: p.InProgress,
+		Missing:    p.Missing(),
+		Satisfied:  p.Satisfied(),
+	}
+}
+
+func convertToMeetingSessionV(s domain.MeetingSession) *coursev1.MeetingSession {
+	return &coursev1.MeetingSession{
+		Weekday:     int32(s.Weekday),
+		StartPeriod: int32(s.StartPeriod),
+		EndPeriod:   int32(s.EndPeriod),
+		Weeks: slice.Map(s.Weeks, func(idx int, src int) int32 {
+			return int32(src)
+		}),
+		Classroom: s.Classroom,
+	}
+}
+
+func convertToTeacherV(t domain.Teacher) *coursev1.Teacher {
+	return &coursev1.Teacher{
+		Id:   t.Id,
+		Name: t.Name,
+	}
+}
+
 func convertToCourseV(c domain.Course) *coursev1.Course {
 	return &coursev1.Course{
-		Id:         c.Id,
-		CourseCode: c.CourseCode,
-		Name:       c.Name,
-		Teacher:    c.Teacher,
-		School:     c.School,
-		Property:   c.Property, // 发到外面就换成string，易于上游理解，内部是为了性能
-		Credit:     c.Credit,
+		Id:           c.Id,
+		CourseCode:   c.CourseCode,
+		Name:         c.Name,
+		Teacher:      c.Teacher,
+		School:       c.School,
+		Property:     c.Property, // 发到外面就换成string，易于上游理解，内部是为了性能
+		Credit:       c.Credit,
+		DepartmentId: c.DepartmentId,
 	}
 }
 
@@ -95,15 +556,83 @@ func convertToCourseDomain(c *coursev1.Course) domain.Course {
 func convertToCourseSubscriptionV(cs domain.CourseSubscription) *coursev1.CourseSubscription {
 	return &coursev1.CourseSubscription{
 		Course: &coursev1.Course{
-			Id:         cs.Course.Id,
-			CourseCode: cs.Course.CourseCode,
-			Name:       cs.Course.Name,
-			Teacher:    cs.Course.Teacher,
-			School:     cs.Term,
-			Property:   cs.Course.Property,
-			Credit:     cs.Course.Credit,
+			Id:           cs.Course.Id,
+			CourseCode:   cs.Course.CourseCode,
+			Name:         cs.Course.Name,
+			Teacher:      cs.Course.Teacher,
+			School:       cs.Course.School,
+			Property:     cs.Course.Property,
+			Credit:       cs.Course.Credit,
+			DepartmentId: cs.Course.DepartmentId,
 		},
-		Year: cs.Year,
-		Term: cs.Term,
+		Year:  cs.Semester.YearStr(),
+		Term:  cs.Semester.TermStr(),
+		Grade: convertToSubscriptionGradeV(cs.Grade),
+	}
+}
+
+func convertToSubscriptionGradeV(g *domain.SubscriptionGrade) *coursev1.SubscriptionGrade {
+	if g == nil {
+		return nil
+	}
+	return &coursev1.SubscriptionGrade{
+		Regular:   g.Grade.Regular,
+		Final:     g.Grade.Final,
+		Total:     g.Grade.Total,
+		Source:    g.Source,
+		FetchedAt: g.FetchedAt.UnixMilli(),
+	}
+}
+
+func convertToAcademicTermV(t domain.AcademicTerm) *coursev1.AcademicTerm {
+	return &coursev1.AcademicTerm{
+		Year:               t.Semester.YearStr(),
+		Term:               t.Semester.TermStr(),
+		StartTime:          t.StartTime.UnixMilli(),
+		EndTime:            t.EndTime.UnixMilli(),
+		SelectionStartTime: t.SelectionStartTime.UnixMilli(),
+		SelectionEndTime:   t.SelectionEndTime.UnixMilli(),
+	}
+}
+
+// convertToAcademicTermDomain 只做转换，没传的时间戳是 0，由 CalendarService.UpsertTerm 拦下来
+func convertToAcademicTermDomain(t *coursev1.AcademicTerm) (domain.AcademicTerm, error) {
+	semester, err := domain.ParseSemester(t.GetYear(), t.GetTerm())
+	return domain.AcademicTerm{
+		Semester:           semester,
+		StartTime:          time.UnixMilli(t.GetStartTime()),
+		EndTime:            time.UnixMilli(t.GetEndTime()),
+		SelectionStartTime: time.UnixMilli(t.GetSelectionStartTime()),
+		SelectionEndTime:   time.UnixMilli(t.GetSelectionEndTime()),
+	}, err
+}
+
+func convertToGradeStatsV(stats domain.CourseGradeStats) *coursev1.GradeStats {
+	return &coursev1.GradeStats{
+		Overall: convertToGradeSummaryV(stats.Overall),
+		BySemester: slice.Map(stats.BySemester, func(idx int, src domain.SemesterGradeSummary) *coursev1.SemesterGradeSummary {
+			return &coursev1.SemesterGradeSummary{
+				Year:    src.Semester.YearStr(),
+				Term:    src.Semester.TermStr(),
+				Summary: convertToGradeSummaryV(src.Summary),
+			}
+		}),
+		ByTeacher: slice.Map(stats.ByTeacher, func(idx int, src domain.TeacherGradeSummary) *coursev1.TeacherGradeSummary {
+			return &coursev1.TeacherGradeSummary{
+				CourseId: src.CourseId,
+				Teacher:  src.Teacher,
+				Summary:  convertToGradeSummaryV(src.Summary),
+			}
+		}),
+	}
+}
+
+func convertToGradeSummaryV(s domain.GradeSummary) *coursev1.GradeSummary {
+	p := s.Publish()
+	return &coursev1.GradeSummary{
+		SampleSize: p.SampleSize,
+		Avg:        p.Avg,
+		PassRate:   p.PassRate,
+		Histogram:  p.Histogram[:],
 	}
 }
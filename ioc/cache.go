@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"time"
+)
+
+func InitCourseCache(client redis.UniversalClient, l logger.Logger) cache.CourseCache {
+	type Config struct {
+		Enabled bool  `yaml:"enabled"`
+		Size    int   `yaml:"size"`
+		TTL     int64 `yaml:"TTL"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("cache.course.local", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	redisCache := cache.NewRedisCourseCache(client)
+	if !cfg.Enabled {
+		return redisCache
+	}
+	localCache := cache.NewLocalCourseCache(redisCache, client, cfg.Size, time.Duration(cfg.TTL)*time.Minute, l)
+	localCache.StartEvictListener(context.Background())
+	return localCache
+}
@@ -1,23 +1,25 @@
 package ioc
 
 import (
+	"fmt"
 	ccnuv1 "github.com/MuxiKeStack/be-api/gen/proto/ccnu/v1"
+	"github.com/MuxiKeStack/be-course/domain"
 	"github.com/MuxiKeStack/be-course/event"
 	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/repository"
 	"github.com/MuxiKeStack/be-course/service"
 	"github.com/spf13/viper"
+	"strings"
 	"time"
 )
 
-func InitFallBackCourseService(ccnu ccnuv1.CCNUServiceClient, repo repository.CourseRepository,
-	producer event.Producer, l logger.Logger, subRepo repository.CourseSubscriptionRepository) service.CourseService {
+// InitCalendarService 配置文件中的 current 只作为校历没有数据时的兜底
+func InitCalendarService(repo repository.CalendarRepository, l logger.Logger) service.CalendarService {
 	type Config struct {
 		Year   string `yaml:"year"`
 		Term   string `yaml:"term"`
 		Course struct {
-			Selecting bool  `yaml:"selecting"`
-			TTL       int64 `yaml:"TTL"`
+			Selecting bool `yaml:"selecting"`
 		} `yaml:"course"`
 	}
 	var cfg *Config
@@ -25,19 +27,40 @@ func InitFallBackCourseService(ccnu ccnuv1.CCNUServiceClient, repo repository.Co
 	if err != nil {
 		panic(err)
 	}
-	courseService := service.NewCourseService(ccnu, repo, subRepo, cfg.Year, cfg.Term)
-	fc := service.NewFallbackCourseService(courseService, repo, producer, l, cfg.Year, cfg.Term, cfg.Course.Selecting)
+	semester, err := domain.ParseSemester(cfg.Year, cfg.Term)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewCalendarService(repo, domain.CurrentTerm{
+		Semester:  semester,
+		Selecting: cfg.Course.Selecting,
+	}, l)
+}
+
+func InitFallBackCourseService(ccnu ccnuv1.CCNUServiceClient, repo repository.CourseRepository,
+	producer event.Producer, l logger.Logger, subRepo repository.CourseSubscriptionRepository,
+	calendar service.CalendarService, offeringRepo repository.CourseOfferingRepository) service.CourseService {
+	courseService := service.NewCourseService(ccnu, repo, subRepo, calendar, offeringRepo, l)
+	fc := service.NewFallbackCourseService(courseService, repo, producer, l, calendar)
 	return fc
 }
 
 func InitPerformanceFallBackCourseService(ccnu ccnuv1.CCNUServiceClient, repo repository.CourseRepository,
-	producer event.Producer, l logger.Logger, subRepo repository.CourseSubscriptionRepository) service.CourseService {
+	producer event.Producer, l logger.Logger, subRepo repository.CourseSubscriptionRepository,
+	calendar service.CalendarService, offeringRepo repository.CourseOfferingRepository) service.CourseService {
+	courseService := service.NewCourseService(ccnu, repo, subRepo, calendar, offeringRepo, l)
+	fc := service.NewFallbackCourseService(courseService, repo, producer, l, calendar)
+	courseTTL := loadCourseTTL()
+	//courseTTL := time.Second
+	pfc := service.NewPerformanceCourseService(fc, repo, calendar, courseTTL, l)
+	return pfc
+}
+
+// loadCourseTTL 当前学年期的修读记录多久没有从教务系统确认过就不作数了，选课结束之后也可能退课
+func loadCourseTTL() time.Duration {
 	type Config struct {
-		Year   string `yaml:"year"`
-		Term   string `yaml:"term"`
 		Course struct {
-			Selecting bool  `yaml:"selecting"`
-			TTL       int64 `yaml:"TTL"`
+			TTL int64 `yaml:"TTL"`
 		} `yaml:"course"`
 	}
 	var cfg *Config
@@ -45,11 +68,71 @@ func InitPerformanceFallBackCourseService(ccnu ccnuv1.CCNUServiceClient, repo re
 	if err != nil {
 		panic(err)
 	}
-	courseService := service.NewCourseService(ccnu, repo, subRepo, cfg.Year, cfg.Term)
-	fc := service.NewFallbackCourseService(courseService, repo, producer, l, cfg.Year, cfg.Term, cfg.Course.Selecting)
-	courseTTL := time.Duration(cfg.Course.TTL) * time.Hour * 24
-	//courseTTL := time.Second
-	pfc := service.NewPerformanceCourseService(fc, repo, cfg.Year,
-		cfg.Term, cfg.Course.Selecting, courseTTL, l)
-	return pfc
+	return time.Duration(cfg.Course.TTL) * time.Hour * 24
+}
+
+func InitEvaluableService(subRepo repository.CourseSubscriptionRepository,
+	calendar service.CalendarService) service.EvaluableService {
+	return service.NewEvaluableService(subRepo, calendar, loadCourseTTL())
+}
+
+// InitAdminService 名单为空时所有管理接口都不能调用
+func InitAdminService() service.AdminService {
+	type Config struct {
+		Uids []int64 `yaml:"uids"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("admin", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewAdminService(cfg.Uids)
+}
+
+func InitGradeService(repo repository.CourseRepository, gradeRepo repository.CourseGradeRepository) service.GradeService {
+	type Config struct {
+		MinSampleSize int64 `yaml:"minSampleSize"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("grade", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	// 没配或者配成 1 的话就等于直接公布单个人的成绩
+	if cfg.MinSampleSize < 2 {
+		panic(fmt.Errorf("grade.minSampleSize 至少为 2，现在是 %d", cfg.MinSampleSize))
+	}
+	return service.NewGradeService(repo, gradeRepo, cfg.MinSampleSize)
+}
+
+func InitTimetableService(courseSvc service.CourseService, offeringRepo repository.CourseOfferingRepository,
+	calendar service.CalendarService, l logger.Logger) service.TimetableService {
+	type Config struct {
+		Periods []string `yaml:"periods"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("timetable", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	periods := make([]domain.PeriodTime, 0, len(cfg.Periods))
+	for _, p := range cfg.Periods {
+		start, end, ok := strings.Cut(p, "-")
+		if !ok {
+			panic(fmt.Errorf("节次时间格式不对: %s", p))
+		}
+		st, err := time.Parse("15:04", strings.TrimSpace(start))
+		if err != nil {
+			panic(err)
+		}
+		et, err := time.Parse("15:04", strings.TrimSpace(end))
+		if err != nil {
+			panic(err)
+		}
+		periods = append(periods, domain.PeriodTime{
+			Start: time.Duration(st.Hour())*time.Hour + time.Duration(st.Minute())*time.Minute,
+			End:   time.Duration(et.Hour())*time.Hour + time.Duration(et.Minute())*time.Minute,
+		})
+	}
+	return service.NewTimetableService(courseSvc, offeringRepo, calendar, periods, l)
 }
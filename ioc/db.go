@@ -3,6 +3,7 @@ package ioc
 import (
 	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/repository/dao"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -17,6 +18,14 @@ func InitDB(l logger.Logger) *gorm.DB {
 	if err := viper.UnmarshalKey("mysql", &cfg); err != nil {
 		panic(err)
 	}
+	// 写修读记录时靠 ON DUPLICATE KEY UPDATE 影响的行数区分新插入的行，开了 clientFoundRows 就分不出来了
+	mysqlCfg, err := mysqldriver.ParseDSN(cfg.DSN)
+	if err != nil {
+		panic(err)
+	}
+	if mysqlCfg.ClientFoundRows {
+		panic("mysql.dsn 不能开启 clientFoundRows")
+	}
 	db, err := gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{
 		Logger: glogger.New(gormLoggerFunc(l.Debug), glogger.Config{
 			SlowThreshold: 0,
@@ -0,0 +1,78 @@
+package ioc
+
+import (
+	"fmt"
+	"github.com/MuxiKeStack/be-course/job"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/MuxiKeStack/be-course/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// minRelatedCourseCount 同时修过两门课的人数再少就能对上具体是哪几个人修了什么课
+const minRelatedCourseCount = 5
+
+type relatedCourseConfig struct {
+	MinCount int64 `yaml:"minCount"`
+	TopN     int   `yaml:"topN"`
+	Interval int64 `yaml:"interval"` // 单位: 小时
+}
+
+func loadRelatedCourseConfig() relatedCourseConfig {
+	var cfg relatedCourseConfig
+	err := viper.UnmarshalKey("job.relatedCourse", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.MinCount < minRelatedCourseCount {
+		panic(fmt.Errorf("job.relatedCourse.minCount 至少为 %d，现在是 %d", minRelatedCourseCount, cfg.MinCount))
+	}
+	if cfg.TopN <= 0 {
+		panic(fmt.Errorf("job.relatedCourse.topN 要大于 0，现在是 %d", cfg.TopN))
+	}
+	if cfg.Interval <= 0 {
+		panic(fmt.Errorf("job.relatedCourse.interval 要大于 0，现在是 %d", cfg.Interval))
+	}
+	return cfg
+}
+
+func InitRelatedCourseService(repo repository.RelatedCourseRepository, subRepo repository.CourseSubscriptionRepository,
+	courseRepo repository.CourseRepository, l logger.Logger) service.RelatedCourseService {
+	cfg := loadRelatedCourseConfig()
+	return service.NewRelatedCourseService(repo, subRepo, courseRepo, service.RelatedCourseConfig{
+		MinCount: cfg.MinCount,
+		TopN:     cfg.TopN,
+		// 多留一个周期，任务失败一次也不至于没有数据
+		Expiration: time.Duration(cfg.Interval) * time.Hour * 2,
+	}, l)
+}
+
+func InitRelatedCourseJob(svc service.RelatedCourseService, cmd redis.Cmdable, l logger.Logger) *job.RelatedCourseJob {
+	cfg := loadRelatedCourseConfig()
+	return job.NewRelatedCourseJob(svc, cmd, time.Duration(cfg.Interval)*time.Hour, l)
+}
+
+func InitHotCourseJob(svc service.HotCourseService, cmd redis.Cmdable, l logger.Logger) *job.HotCourseJob {
+	type Config struct {
+		Interval int64 `yaml:"interval"` // 单位: 小时
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("job.hotCourse", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	// time.NewTicker 不接受 0
+	if cfg.Interval <= 0 {
+		panic(fmt.Errorf("job.hotCourse.interval 要大于 0，现在是 %d", cfg.Interval))
+	}
+	return job.NewHotCourseJob(svc, cmd, time.Duration(cfg.Interval)*time.Hour, l)
+}
+
+func InitJobs(relatedCourse *job.RelatedCourseJob, hotCourse *job.HotCourseJob) []job.Job {
+	return []job.Job{
+		relatedCourse,
+		hotCourse,
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"github.com/spf13/viper"
 )
 
-func InitRedis() redis.Cmdable {
+func InitRedisClient() redis.UniversalClient {
 	type Config struct {
 		Addr     string `yaml:"addr"`
 		Password string `yaml:"password"`
@@ -17,3 +17,8 @@ func InitRedis() redis.Cmdable {
 	}
 	return redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password})
 }
+
+// InitRedis 绝大多数地方只需要 Cmdable，订阅之类的才需要完整的 client
+func InitRedis(client redis.UniversalClient) redis.Cmdable {
+	return client
+}
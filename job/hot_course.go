@@ -0,0 +1,45 @@
+package job
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/service"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const hotCourseLockKey = "kstack:jobs:hot_courses:lock"
+
+// HotCourseJob 定时按修读人数重建当前学年期的热门课程排行，平时靠消费修读事件增量更新
+type HotCourseJob struct {
+	svc      service.HotCourseService
+	cmd      redis.Cmdable
+	interval time.Duration
+	l        logger.Logger
+}
+
+func NewHotCourseJob(svc service.HotCourseService, cmd redis.Cmdable, interval time.Duration,
+	l logger.Logger) *HotCourseJob {
+	return &HotCourseJob{svc: svc, cmd: cmd, interval: interval, l: l}
+}
+
+func (j *HotCourseJob) Start() error {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		j.run()
+		for range ticker.C {
+			j.run()
+		}
+	}()
+	return nil
+}
+
+func (j *HotCourseJob) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), j.interval)
+	defer cancel()
+	_, err := runWithLock(ctx, j.cmd, hotCourseLockKey, j.interval, j.svc.Rebuild)
+	if err != nil {
+		j.l.Error("热门课程任务执行失败", logger.Error(err))
+	}
+}
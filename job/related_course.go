@@ -0,0 +1,52 @@
+package job
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/service"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const relatedCourseLockKey = "kstack:jobs:related_courses:lock"
+
+// RelatedCourseJob 定时重新计算相关课程，多个实例通过 redis 抢锁，每个周期整个集群只跑一次
+type RelatedCourseJob struct {
+	svc      service.RelatedCourseService
+	cmd      redis.Cmdable
+	interval time.Duration
+	l        logger.Logger
+}
+
+func NewRelatedCourseJob(svc service.RelatedCourseService, cmd redis.Cmdable, interval time.Duration,
+	l logger.Logger) *RelatedCourseJob {
+	return &RelatedCourseJob{svc: svc, cmd: cmd, interval: interval, l: l}
+}
+
+func (j *RelatedCourseJob) Start() error {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		// 启动时先跑一次，别的实例正在跑或者这个周期已经跑过的话抢不到锁，直接跳过
+		j.run()
+		for range ticker.C {
+			j.run()
+		}
+	}()
+	return nil
+}
+
+func (j *RelatedCourseJob) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), j.interval)
+	defer cancel()
+	start := time.Now()
+	ok, err := runWithLock(ctx, j.cmd, relatedCourseLockKey, j.interval, j.svc.Refresh)
+	if err != nil {
+		j.l.Error("相关课程任务执行失败", logger.Error(err))
+		return
+	}
+	if !ok {
+		return
+	}
+	j.l.Info("相关课程任务执行完成", logger.String("cost", time.Since(start).String()))
+}
@@ -0,0 +1,88 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Job 离线任务，和 saramax.Consumer 一样自己启动 goroutine
+type Job interface {
+	Start() error
+}
+
+const (
+	// 锁的过期时间很短，实例挂了的话别的实例很快就能接着跑，正常跑的时候靠续约保持
+	lockTTL           = time.Minute
+	lockRenewInterval = lockTTL / 3
+)
+
+var (
+	// 只能续约和释放自己的锁，锁过期之后可能已经被别的实例抢到了
+	renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+var errLockLost = errors.New("任务锁续约失败，可能已经被别的实例拿到了")
+
+// runWithLock 多个实例抢同一个任务，抢到了才执行 fn，没抢到返回 false
+// 执行期间定时续约，续约失败就取消 fn 的 ctx，免得两个实例同时在跑
+// 成功之后锁一直留到这个周期快结束，其他实例的定时器在这个周期里都抢不到，整个集群每个周期只跑一次；失败了马上释放，别的实例可以重试
+func runWithLock(ctx context.Context, cmd redis.Cmdable, key string, interval time.Duration,
+	fn func(ctx context.Context) error) (bool, error) {
+	token := uuid.NewString()
+	ok, err := cmd.SetNX(ctx, key, token, lockTTL).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	start := time.Now()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				res, er := renewLockScript.Run(ctx, cmd, []string{key}, token, lockTTL.Milliseconds()).Int()
+				if er != nil || res == 0 {
+					cancel(errLockLost)
+					return
+				}
+			}
+		}
+	}()
+	err = fn(ctx)
+	close(done)
+	// 等续约停下来，免得它把下面留到周期结束的过期时间又改回 lockTTL
+	<-stopped
+	if context.Cause(ctx) == errLockLost {
+		return true, errLockLost
+	}
+	// 任务超时了 ctx 也已经取消了，要用新的 ctx，失败了也没关系，锁很快会过期
+	rctx, rcancel := context.WithTimeout(context.Background(), time.Second)
+	defer rcancel()
+	// 留一个 lockTTL 的余量，让这次跑的实例下个周期还能接着抢到
+	hold := interval - lockTTL - time.Since(start)
+	if err != nil || hold <= 0 {
+		unlockScript.Run(rctx, cmd, []string{key}, token)
+		return true, err
+	}
+	renewLockScript.Run(rctx, cmd, []string{key}, token, hold.Milliseconds())
+	return true, nil
+}
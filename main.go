@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/MuxiKeStack/be-course/job"
 	"github.com/MuxiKeStack/be-course/pkg/grpcx"
 	"github.com/MuxiKeStack/be-course/pkg/saramax"
 	"github.com/spf13/pflag"
@@ -16,6 +17,12 @@ func main() {
 			panic(err)
 		}
 	}
+	for _, j := range app.jobs {
+		err := j.Start()
+		if err != nil {
+			panic(err)
+		}
+	}
 	err := app.server.Serve()
 	if err != nil {
 		panic(err)
@@ -38,4 +45,5 @@ func initViper() {
 type App struct {
 	server    grpcx.Server
 	consumers []saramax.Consumer
+	jobs      []job.Job
 }
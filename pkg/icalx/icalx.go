@@ -0,0 +1,128 @@
+// Package icalx 生成 iCalendar（RFC 5545）格式的日历，只实现了课表用得到的部分
+package icalx
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// RFC 5545 3.1 一行最多 75 个字节，超过了要折行
+	maxLineOctets = 75
+	dateTimeFmt   = "20060102T150405"
+)
+
+type Calendar struct {
+	ProdId string
+	Name   string
+	// Description 整个日历的说明，日历应用一般显示在日历的详情里
+	Description string
+	// TZID 和 Location 对应同一个时区，只支持没有夏令时的时区
+	TZID     string
+	Location *time.Location
+	Events   []Event
+}
+
+// Event 一个可能每周重复的日程，Start、End 都是第一次发生的时间
+type Event struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Start       time.Time
+	End         time.Time
+	// Until 最后一次发生的开始时间，零值表示不重复
+	Until time.Time
+	// ExDates 重复范围内跳过的那几次的开始时间
+	ExDates []time.Time
+}
+
+// Marshal now 用作 DTSTAMP
+func (c Calendar) Marshal(now time.Time) []byte {
+	var buf bytes.Buffer
+	w := func(line string) {
+		writeFolded(&buf, line)
+	}
+	w("BEGIN:VCALENDAR")
+	w("VERSION:2.0")
+	w("PRODID:" + c.ProdId)
+	w("CALSCALE:GREGORIAN")
+	w("METHOD:PUBLISH")
+	if c.Name != "" {
+		w("X-WR-CALNAME:" + escape(c.Name))
+	}
+	if c.Description != "" {
+		w("X-WR-CALDESC:" + escape(c.Description))
+	}
+	w("X-WR-TIMEZONE:" + c.TZID)
+	_, offset := now.In(c.Location).Zone()
+	w("BEGIN:VTIMEZONE")
+	w("TZID:" + c.TZID)
+	w("BEGIN:STANDARD")
+	w("DTSTART:19700101T000000")
+	w("TZOFFSETFROM:" + formatOffset(offset))
+	w("TZOFFSETTO:" + formatOffset(offset))
+	w("END:STANDARD")
+	w("END:VTIMEZONE")
+	stamp := now.UTC().Format(dateTimeFmt) + "Z"
+	for _, e := range c.Events {
+		w("BEGIN:VEVENT")
+		w("UID:" + e.UID)
+		w("DTSTAMP:" + stamp)
+		w(fmt.Sprintf("DTSTART;TZID=%s:%s", c.TZID, e.Start.In(c.Location).Format(dateTimeFmt)))
+		w(fmt.Sprintf("DTEND;TZID=%s:%s", c.TZID, e.End.In(c.Location).Format(dateTimeFmt)))
+		if !e.Until.IsZero() {
+			// DTSTART 带时区时，UNTIL 要用 UTC
+			w("RRULE:FREQ=WEEKLY;UNTIL=" + e.Until.UTC().Format(dateTimeFmt) + "Z")
+		}
+		for _, ex := range e.ExDates {
+			w(fmt.Sprintf("EXDATE;TZID=%s:%s", c.TZID, ex.In(c.Location).Format(dateTimeFmt)))
+		}
+		w("SUMMARY:" + escape(e.Summary))
+		if e.Location != "" {
+			w("LOCATION:" + escape(e.Location))
+		}
+		if e.Description != "" {
+			w("DESCRIPTION:" + escape(e.Description))
+		}
+		w("END:VEVENT")
+	}
+	w("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeFolded 折行时续行以一个空格开头，不能把一个 UTF-8 字符拆开
+func writeFolded(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// 续行开头的空格也算一个字节
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
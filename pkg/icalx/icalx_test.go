@@ -0,0 +1,173 @@
+package icalx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteFolded(t *testing.T) {
+	testCases := []struct {
+		name string
+		line string
+		// 折出来的物理行数
+		wantLines int
+	}{
+		{
+			name:      "不超长",
+			line:      "SUMMARY:程序设计",
+			wantLines: 1,
+		},
+		{
+			name:      "正好 75 个字节",
+			line:      strings.Repeat("a", 75),
+			wantLines: 1,
+		},
+		{
+			name:      "76 个字节",
+			line:      strings.Repeat("a", 76),
+			wantLines: 2,
+		},
+		{
+			// "SUMMARY:" 8 个字节，后面每个汉字 3 个字节，第 75 个字节落在一个汉字中间
+			name:      "折行处在多字节字符中间",
+			line:      "SUMMARY:" + strings.Repeat("课", 30),
+			wantLines: 2,
+		},
+		{
+			name:      "续行也要按 74 个字节折",
+			line:      "DESCRIPTION:" + strings.Repeat("表", 60),
+			wantLines: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeFolded(&buf, tc.line)
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("没有以 CRLF 结尾: %q", out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			if len(lines) != tc.wantLines {
+				t.Fatalf("行数 = %d, 期望 %d: %q", len(lines), tc.wantLines, out)
+			}
+			for i, l := range lines {
+				if len(l) > maxLineOctets {
+					t.Errorf("第 %d 行 %d 个字节，超过了 %d", i, len(l), maxLineOctets)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("第 %d 行拆开了 UTF-8 字符: %q", i, l)
+				}
+				if i > 0 && !strings.HasPrefix(l, " ") {
+					t.Errorf("第 %d 行续行没有以空格开头: %q", i, l)
+				}
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""); unfolded != tc.line {
+				t.Errorf("展开之后 = %q, 期望 %q", unfolded, tc.line)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "普通文本", in: "程序设计", want: "程序设计"},
+		{name: "反斜杠", in: `a\b`, want: `a\\b`},
+		{name: "分号和逗号", in: "张三,李四;王五", want: `张三\,李四\;王五`},
+		{name: "换行", in: "第一行\n第二行", want: `第一行\n第二行`},
+		{name: "CRLF 换行", in: "第一行\r\n第二行", want: `第一行\n第二行`},
+		{name: "反斜杠不会被转义两次", in: "\\,", want: `\\\,`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := escape(tc.in); got != tc.want {
+				t.Errorf("escape(%q) = %q, 期望 %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCalendarMarshal(t *testing.T) {
+	loc := time.FixedZone("Asia/Shanghai", 8*60*60)
+	start := time.Date(2024, 2, 26, 8, 0, 0, 0, loc)
+	testCases := []struct {
+		name      string
+		event     Event
+		wantLines []string
+		// 不应该出现的行前缀
+		notWant []string
+	}{
+		{
+			name: "不重复",
+			event: Event{
+				UID:     "1@kstack",
+				Summary: "程序设计",
+				Start:   start,
+				End:     start.Add(45 * time.Minute),
+			},
+			wantLines: []string{
+				"DTSTART;TZID=Asia/Shanghai:20240226T080000",
+				"DTEND;TZID=Asia/Shanghai:20240226T084500",
+				"SUMMARY:程序设计",
+			},
+			notWant: []string{"RRULE:", "EXDATE"},
+		},
+		{
+			name: "每周重复，跳过两周",
+			event: Event{
+				UID:      "2@kstack",
+				Summary:  "高等数学",
+				Location: "N101,N102",
+				Start:    start,
+				End:      start.Add(45 * time.Minute),
+				Until:    start.AddDate(0, 0, 7*15),
+				ExDates:  []time.Time{start.AddDate(0, 0, 7), start.AddDate(0, 0, 7*3)},
+			},
+			wantLines: []string{
+				// UNTIL 要换成 UTC
+				"RRULE:FREQ=WEEKLY;UNTIL=20240610T000000Z",
+				"EXDATE;TZID=Asia/Shanghai:20240304T080000",
+				"EXDATE;TZID=Asia/Shanghai:20240318T080000",
+				`LOCATION:N101\,N102`,
+			},
+		},
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cal := Calendar{
+				ProdId:   "-//test//CN",
+				TZID:     "Asia/Shanghai",
+				Location: loc,
+				Events:   []Event{tc.event},
+			}
+			lines := strings.Split(string(cal.Marshal(now)), "\r\n")
+			has := make(map[string]bool, len(lines))
+			for _, l := range lines {
+				has[l] = true
+			}
+			for _, want := range tc.wantLines {
+				if !has[want] {
+					t.Errorf("缺少 %q", want)
+				}
+			}
+			for _, l := range lines {
+				for _, prefix := range tc.notWant {
+					if strings.HasPrefix(l, prefix) {
+						t.Errorf("不应该有 %q", l)
+					}
+				}
+			}
+			if !has["DTSTAMP:20240101T000000Z"] || !has["TZOFFSETTO:+0800"] {
+				t.Errorf("DTSTAMP 或时区不对: %v", lines)
+			}
+		})
+	}
+}
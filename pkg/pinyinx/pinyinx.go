@@ -0,0 +1,46 @@
+package pinyinx
+
+import (
+	"github.com/mozillazg/go-pinyin"
+	"strings"
+	"unicode"
+)
+
+var (
+	fullArgs     = newArgs(pinyin.Normal)
+	initialsArgs = newArgs(pinyin.FirstLetter)
+)
+
+// Full 将 s 转换为不带声调的全拼，如 "大学体育" -> "daxuetiyu"，
+// 多音字只取第一个读音，字母和数字转小写后原样保留，其余字符丢弃
+func Full(s string) string {
+	return strings.Join(pinyin.LazyPinyin(s, fullArgs), "")
+}
+
+// Initials 将 s 转换为拼音首字母，如 "大学体育" -> "dxty"，规则同 Full
+func Initials(s string) string {
+	return strings.Join(pinyin.LazyPinyin(s, initialsArgs), "")
+}
+
+// ContainsHan 检查字符串 s 是否包含至少一个汉字
+func ContainsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func newArgs(style int) pinyin.Args {
+	a := pinyin.NewArgs()
+	a.Style = style
+	a.Fallback = func(r rune, a pinyin.Args) []string {
+		// 字典里没有的生僻字也会走到这里，直接丢弃
+		if !unicode.Is(unicode.Han, r) && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return []string{strings.ToLower(string(r))}
+		}
+		return nil
+	}
+	return a
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type CalendarCache interface {
+	GetTerms(ctx context.Context) ([]domain.AcademicTerm, error)
+	SetTerms(ctx context.Context, terms []domain.AcademicTerm) error
+	DelTerms(ctx context.Context) error
+}
+
+type RedisCalendarCache struct {
+	cmd redis.Cmdable
+}
+
+func NewRedisCalendarCache(cmd redis.Cmdable) CalendarCache {
+	return &RedisCalendarCache{cmd: cmd}
+}
+
+func (cache *RedisCalendarCache) GetTerms(ctx context.Context) ([]domain.AcademicTerm, error) {
+	val, err := cache.cmd.Get(ctx, cache.termsKey()).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var terms []domain.AcademicTerm
+	err = json.Unmarshal(val, &terms)
+	return terms, err
+}
+
+func (cache *RedisCalendarCache) SetTerms(ctx context.Context, terms []domain.AcademicTerm) error {
+	val, err := json.Marshal(terms)
+	if err != nil {
+		return err
+	}
+	// 校历每次修改都会删缓存，过期时间只是兜底
+	return cache.cmd.Set(ctx, cache.termsKey(), val, time.Hour).Err()
+}
+
+func (cache *RedisCalendarCache) DelTerms(ctx context.Context) error {
+	return cache.cmd.Del(ctx, cache.termsKey()).Err()
+}
+
+func (cache *RedisCalendarCache) termsKey() string {
+	return "kstack:calendar:terms"
+}
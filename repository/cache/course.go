@@ -3,13 +3,39 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/ecodeclub/ekit/slice"
 	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// ErrCourseNotExist 命中了负缓存，说明数据库里确实没有这门课
+var ErrCourseNotExist = errors.New("课程不存在")
+
+const (
+	// 课程信息在一个学期内几乎不变，写操作都会主动删缓存，过期时间可以长一点
+	courseExpiration = time.Hour * 24
+	// 负缓存只是为了挡住不存在的 id 反复打到数据库，时间短一点，免得新建的课程查不到
+	notExistExpiration = time.Minute * 5
+	// 负缓存的值，正常的课程序列化之后不可能是空串
+	notExistVal = ""
+	// 统计本来就要攒够一批成绩变动才会更新，过期之后再去看要不要重新统计就行
+	gradesExpiration = time.Hour * 6
 )
 
 type CourseCache interface {
 	Get(ctx context.Context, id int64) (domain.Course, error)
+	// MGet 只返回命中的课程，没命中的 id 不会出现在结果里，命中负缓存的 id 对应的课程 Id 为 0
+	MGet(ctx context.Context, ids []int64) (map[int64]domain.Course, error)
+	Set(ctx context.Context, c domain.Course) error
+	// SetNotExist 设置负缓存
+	SetNotExist(ctx context.Context, id int64) error
+	Del(ctx context.Context, id int64) error
+	// GetGrades 一门课按学年期汇总的成绩
+	GetGrades(ctx context.Context, cid int64) ([]domain.SemesterGradeSummary, error)
+	SetGrades(ctx context.Context, cid int64, summaries []domain.SemesterGradeSummary) error
 }
 
 type RedisCourseCache struct {
@@ -22,11 +48,82 @@ func NewRedisCourseCache(cmd redis.Cmdable) CourseCache {
 
 func (cache *RedisCourseCache) Get(ctx context.Context, id int64) (domain.Course, error) {
 	val, err := cache.cmd.Get(ctx, cache.key(id)).Bytes()
+	if err != nil {
+		return domain.Course{}, err
+	}
+	if string(val) == notExistVal {
+		return domain.Course{}, ErrCourseNotExist
+	}
 	var c domain.Course
 	err = json.Unmarshal(val, &c)
 	return c, err
 }
 
+func (cache *RedisCourseCache) MGet(ctx context.Context, ids []int64) (map[int64]domain.Course, error) {
+	if len(ids) == 0 {
+		return map[int64]domain.Course{}, nil
+	}
+	keys := slice.Map(ids, func(idx int, src int64) string {
+		return cache.key(src)
+	})
+	vals, err := cache.cmd.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.Course, len(ids))
+	for i, val := range vals {
+		// 没命中的 key 对应的是 nil
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+		if str == notExistVal {
+			res[ids[i]] = domain.Course{}
+			continue
+		}
+		var c domain.Course
+		if json.Unmarshal([]byte(str), &c) != nil {
+			continue
+		}
+		res[ids[i]] = c
+	}
+	return res, nil
+}
+
+func (cache *RedisCourseCache) Set(ctx context.Context, c domain.Course) error {
+	val, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return cache.cmd.Set(ctx, cache.key(c.Id), val, courseExpiration).Err()
+}
+
+func (cache *RedisCourseCache) SetNotExist(ctx context.Context, id int64) error {
+	return cache.cmd.Set(ctx, cache.key(id), notExistVal, notExistExpiration).Err()
+}
+
+func (cache *RedisCourseCache) Del(ctx context.Context, id int64) error {
+	return cache.cmd.Del(ctx, cache.key(id)).Err()
+}
+
+func (cache *RedisCourseCache) GetGrades(ctx context.Context, cid int64) ([]domain.SemesterGradeSummary, error) {
+	val, err := cache.cmd.Get(ctx, cache.gradesKey(cid)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var res []domain.SemesterGradeSummary
+	err = json.Unmarshal(val, &res)
+	return res, err
+}
+
+func (cache *RedisCourseCache) SetGrades(ctx context.Context, cid int64, summaries []domain.SemesterGradeSummary) error {
+	val, err := json.Marshal(summaries)
+	if err != nil {
+		return err
+	}
+	return cache.cmd.Set(ctx, cache.gradesKey(cid), val, gradesExpiration).Err()
+}
+
 func (cache *RedisCourseCache) key(id int64) string {
 	return fmt.Sprintf("kstack:courses:%d", id)
 }
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type CourseFavoriteCache interface {
+	GetCount(ctx context.Context, courseId int64) (int64, error)
+	SetCount(ctx context.Context, courseId int64, cnt int64) error
+	DelCount(ctx context.Context, courseId int64) error
+}
+
+type RedisCourseFavoriteCache struct {
+	cmd redis.Cmdable
+}
+
+func NewRedisCourseFavoriteCache(cmd redis.Cmdable) CourseFavoriteCache {
+	return &RedisCourseFavoriteCache{cmd: cmd}
+}
+
+func (cache *RedisCourseFavoriteCache) GetCount(ctx context.Context, courseId int64) (int64, error) {
+	return cache.cmd.Get(ctx, cache.countKey(courseId)).Int64()
+}
+
+func (cache *RedisCourseFavoriteCache) SetCount(ctx context.Context, courseId int64, cnt int64) error {
+	// 收藏、取消收藏都会删缓存，过期时间只是兜底
+	return cache.cmd.Set(ctx, cache.countKey(courseId), cnt, time.Hour*24).Err()
+}
+
+func (cache *RedisCourseFavoriteCache) DelCount(ctx context.Context, courseId int64) error {
+	return cache.cmd.Del(ctx, cache.countKey(courseId)).Err()
+}
+
+func (cache *RedisCourseFavoriteCache) countKey(courseId int64) string {
+	return fmt.Sprintf("kstack:courses:%d:favorite_count", courseId)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// 学年期过去之后排行也没人看了，保留一年够查历史
+const hotCourseExpiration = time.Hour * 24 * 366
+
+// HotCourseCache 每个学年期按 全部、学院、课程性质、学院+课程性质 各维护一个有序集合，写的时候四个一起写
+type HotCourseCache interface {
+	// BatchIncr courses 里每出现一次加一，课程要带上学院和课程性质
+	BatchIncr(ctx context.Context, semester domain.Semester, courses []domain.Course) error
+	// Replace 用离线统计的结果覆盖，课程要带上学院和课程性质
+	// 统计之后、覆盖之前这段时间 BatchIncr 加上的会被覆盖掉，要等下一次重建才能补回来
+	Replace(ctx context.Context, semester domain.Semester, hots []domain.HotCourse) error
+	// Top 返回的课程只有 Id
+	Top(ctx context.Context, semester domain.Semester, filter domain.HotCourseFilter, limit int64) ([]domain.HotCourse, error)
+}
+
+type RedisHotCourseCache struct {
+	cmd redis.Cmdable
+}
+
+func NewRedisHotCourseCache(cmd redis.Cmdable) HotCourseCache {
+	return &RedisHotCourseCache{cmd: cmd}
+}
+
+func (cache *RedisHotCourseCache) BatchIncr(ctx context.Context, semester domain.Semester, courses []domain.Course) error {
+	if len(courses) == 0 {
+		return nil
+	}
+	_, err := cache.cmd.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, c := range courses {
+			for _, key := range cache.keys(semester, c) {
+				pipe.ZIncrBy(ctx, key, 1, strconv.FormatInt(c.Id, 10))
+				pipe.Expire(ctx, key, hotCourseExpiration)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (cache *RedisHotCourseCache) Replace(ctx context.Context, semester domain.Semester, hots []domain.HotCourse) error {
+	members := make(map[string][]redis.Z)
+	for _, h := range hots {
+		for _, key := range cache.keys(semester, h.Course) {
+			members[key] = append(members[key], redis.Z{Score: float64(h.Count), Member: h.Course.Id})
+		}
+	}
+	// 修读人数只增不减，不会有该删掉的 key
+	// 不和重建期间的增量合并：修读事件比写库晚，统计结果里已经有的修读记录，事件可能还没消费，
+	// 合并的话这部分会重复计数，而且下一次重建也纠正不回来；丢掉的增量最多一个周期就补回来了
+	_, err := cache.cmd.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, zs := range members {
+			pipe.Del(ctx, key)
+			pipe.ZAdd(ctx, key, zs...)
+			pipe.Expire(ctx, key, hotCourseExpiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func (cache *RedisHotCourseCache) Top(ctx context.Context, semester domain.Semester, filter domain.HotCourseFilter,
+	limit int64) ([]domain.HotCourse, error) {
+	zs, err := cache.cmd.ZRevRangeWithScores(ctx, cache.key(semester, filter), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return slice.FilterMap(zs, func(idx int, src redis.Z) (domain.HotCourse, bool) {
+		member, _ := src.Member.(string)
+		id, er := strconv.ParseInt(member, 10, 64)
+		return domain.HotCourse{
+			Course: domain.Course{Id: id},
+			Count:  int64(src.Score),
+		}, er == nil
+	}), nil
+}
+
+// keys 一门课会出现在的所有排行
+func (cache *RedisHotCourseCache) keys(semester domain.Semester, c domain.Course) []string {
+	// 零值表示不筛选，课程性质未知或者没有学院的课只进对应的上一级排行
+	keys := []string{cache.key(semester, domain.HotCourseFilter{})}
+	if c.Property != 0 {
+		keys = append(keys, cache.key(semester, domain.HotCourseFilter{Property: c.Property}))
+	}
+	if c.DepartmentId != 0 {
+		keys = append(keys, cache.key(semester, domain.HotCourseFilter{DepartmentId: c.DepartmentId}))
+		if c.Property != 0 {
+			keys = append(keys, cache.key(semester, domain.HotCourseFilter{DepartmentId: c.DepartmentId, Property: c.Property}))
+		}
+	}
+	return keys
+}
+
+func (cache *RedisHotCourseCache) key(semester domain.Semester, filter domain.HotCourseFilter) string {
+	return fmt.Sprintf("kstack:hot_courses:%s:%d:%d", semester.String(), filter.DepartmentId, filter.Property)
+}
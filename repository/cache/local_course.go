@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// courseEvictChannel 任意一个实例删除课程缓存时，通过这个频道通知所有实例删除本地缓存
+const courseEvictChannel = "kstack:courses:evict"
+
+// LocalCourseCache 本地缓存装饰层，课程信息在学期稳定之后几乎不变，放在本地可以省掉一次 redis 往返
+// 只缓存存在的课程，负缓存仍然交给下一层
+type LocalCourseCache struct {
+	CourseCache
+	local  *expirable.LRU[int64, domain.Course]
+	client redis.UniversalClient
+	l      logger.Logger
+}
+
+func NewLocalCourseCache(courseCache CourseCache, client redis.UniversalClient, size int, ttl time.Duration,
+	l logger.Logger) *LocalCourseCache {
+	return &LocalCourseCache{
+		CourseCache: courseCache,
+		local:       expirable.NewLRU[int64, domain.Course](size, nil, ttl),
+		client:      client,
+		l:           l,
+	}
+}
+
+func (cache *LocalCourseCache) Get(ctx context.Context, id int64) (domain.Course, error) {
+	if c, ok := cache.local.Get(id); ok {
+		return c, nil
+	}
+	c, err := cache.CourseCache.Get(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	cache.local.Add(id, c)
+	return c, nil
+}
+
+func (cache *LocalCourseCache) MGet(ctx context.Context, ids []int64) (map[int64]domain.Course, error) {
+	res := make(map[int64]domain.Course, len(ids))
+	missIds := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if c, ok := cache.local.Get(id); ok {
+			res[id] = c
+			continue
+		}
+		missIds = append(missIds, id)
+	}
+	if len(missIds) == 0 {
+		return res, nil
+	}
+	hits, err := cache.CourseCache.MGet(ctx, missIds)
+	if err != nil {
+		return nil, err
+	}
+	for id, c := range hits {
+		res[id] = c
+		// Id 为 0 的是负缓存，本地不存
+		if c.Id != 0 {
+			cache.local.Add(id, c)
+		}
+	}
+	return res, nil
+}
+
+func (cache *LocalCourseCache) Set(ctx context.Context, c domain.Course) error {
+	err := cache.CourseCache.Set(ctx, c)
+	if err != nil {
+		return err
+	}
+	cache.local.Add(c.Id, c)
+	return nil
+}
+
+func (cache *LocalCourseCache) SetNotExist(ctx context.Context, id int64) error {
+	cache.local.Remove(id)
+	return cache.CourseCache.SetNotExist(ctx, id)
+}
+
+func (cache *LocalCourseCache) Del(ctx context.Context, id int64) error {
+	cache.local.Remove(id)
+	err := cache.CourseCache.Del(ctx, id)
+	if err != nil {
+		return err
+	}
+	// 通知其他实例，自己也会收到，重复删一次没关系
+	return cache.client.Publish(ctx, courseEvictChannel, id).Err()
+}
+
+// StartEvictListener 订阅其他实例的删除通知，这边就是自己启动 goroutine 了
+// go-redis 的 PubSub 断线会自己重连，重连期间漏掉的通知只能等本地缓存过期
+func (cache *LocalCourseCache) StartEvictListener(ctx context.Context) {
+	pubSub := cache.client.Subscribe(ctx, courseEvictChannel)
+	go func() {
+		defer pubSub.Close()
+		for msg := range pubSub.Channel() {
+			id, err := strconv.ParseInt(msg.Payload, 10, 64)
+			if err != nil {
+				cache.l.Error("解析课程缓存删除通知失败", logger.Error(err), logger.String("payload", msg.Payload))
+				continue
+			}
+			cache.local.Remove(id)
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+type RelatedCourseCache interface {
+	// Get 按相似度降序，没有数据时返回空切片
+	Get(ctx context.Context, cid int64, limit int64) ([]domain.RelatedCourse, error)
+	// Set 整体替换，related 为空时删除
+	Set(ctx context.Context, cid int64, related []domain.RelatedCourse, expiration time.Duration) error
+}
+
+type RedisRelatedCourseCache struct {
+	cmd redis.Cmdable
+}
+
+func NewRedisRelatedCourseCache(cmd redis.Cmdable) RelatedCourseCache {
+	return &RedisRelatedCourseCache{cmd: cmd}
+}
+
+func (cache *RedisRelatedCourseCache) Get(ctx context.Context, cid int64, limit int64) ([]domain.RelatedCourse, error) {
+	zs, err := cache.cmd.ZRevRangeWithScores(ctx, cache.key(cid), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return slice.FilterMap(zs, func(idx int, src redis.Z) (domain.RelatedCourse, bool) {
+		member, _ := src.Member.(string)
+		id, er := strconv.ParseInt(member, 10, 64)
+		return domain.RelatedCourse{
+			Course: domain.Course{Id: id},
+			Score:  src.Score,
+		}, er == nil
+	}), nil
+}
+
+func (cache *RedisRelatedCourseCache) Set(ctx context.Context, cid int64, related []domain.RelatedCourse,
+	expiration time.Duration) error {
+	key := cache.key(cid)
+	// 事务里先删再写，读的人不会看到一半新一半旧的结果
+	_, err := cache.cmd.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(related) == 0 {
+			return nil
+		}
+		pipe.ZAdd(ctx, key, slice.Map(related, func(idx int, src domain.RelatedCourse) redis.Z {
+			return redis.Z{Score: src.Score, Member: src.Course.Id}
+		})...)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (cache *RedisRelatedCourseCache) key(cid int64) string {
+	return fmt.Sprintf("kstack:courses:related:%d", cid)
+}
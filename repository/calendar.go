@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/ecodeclub/ekit/slice"
+	"time"
+)
+
+type CalendarRepository interface {
+	Upsert(ctx context.Context, term domain.AcademicTerm) error
+	// FindAll 按学年期升序返回
+	FindAll(ctx context.Context) ([]domain.AcademicTerm, error)
+}
+
+type CachedCalendarRepository struct {
+	dao   dao.CalendarDAO
+	cache cache.CalendarCache
+	l     logger.Logger
+}
+
+func NewCachedCalendarRepository(dao dao.CalendarDAO, cache cache.CalendarCache, l logger.Logger) CalendarRepository {
+	return &CachedCalendarRepository{dao: dao, cache: cache, l: l}
+}
+
+func (repo *CachedCalendarRepository) Upsert(ctx context.Context, term domain.AcademicTerm) error {
+	err := repo.dao.Upsert(ctx, repo.toEntity(term))
+	if err != nil {
+		return err
+	}
+	return repo.cache.DelTerms(ctx)
+}
+
+func (repo *CachedCalendarRepository) FindAll(ctx context.Context) ([]domain.AcademicTerm, error) {
+	// 每次查询课程都要判断当前学期，这个是高频的，先查缓存
+	res, err := repo.cache.GetTerms(ctx)
+	if err == nil {
+		return res, nil
+	}
+	if err != cache.ErrKeyNotExist {
+		repo.l.Error("查询校历缓存失败", logger.Error(err))
+	}
+	terms, err := repo.dao.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res = slice.Map(terms, func(idx int, src dao.AcademicTerm) domain.AcademicTerm {
+		return repo.toDomain(src)
+	})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		er := repo.cache.SetTerms(ctx, res)
+		if er != nil {
+			repo.l.Error("回写校历缓存失败", logger.Error(er))
+		}
+	}()
+	return res, nil
+}
+
+func (repo *CachedCalendarRepository) toEntity(t domain.AcademicTerm) dao.AcademicTerm {
+	return dao.AcademicTerm{
+		Year:               t.Semester.YearStr(),
+		Term:               t.Semester.TermStr(),
+		StartTime:          t.StartTime.UnixMilli(),
+		EndTime:            t.EndTime.UnixMilli(),
+		SelectionStartTime: t.SelectionStartTime.UnixMilli(),
+		SelectionEndTime:   t.SelectionEndTime.UnixMilli(),
+	}
+}
+
+func (repo *CachedCalendarRepository) toDomain(t dao.AcademicTerm) domain.AcademicTerm {
+	// 写入时校验过了，这里不会出错
+	semester, _ := domain.ParseSemester(t.Year, t.Term)
+	return domain.AcademicTerm{
+		Semester:           semester,
+		StartTime:          time.UnixMilli(t.StartTime),
+		EndTime:            time.UnixMilli(t.EndTime),
+		SelectionStartTime: time.UnixMilli(t.SelectionStartTime),
+		SelectionEndTime:   time.UnixMilli(t.SelectionEndTime),
+	}
+}
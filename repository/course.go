@@ -4,8 +4,11 @@ import (
 	"context"
 	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
 	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/repository/cache"
 	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/ecodeclub/ekit/slice"
+	"time"
 )
 
 var (
@@ -15,23 +18,39 @@ var (
 
 type CourseRepository interface {
 	FindById(ctx context.Context, id int64) (domain.Course, error)
+	// FindByIds 按 ids 的顺序返回，不存在的 id 会被跳过
+	FindByIds(ctx context.Context, ids []int64) ([]domain.Course, error)
 	FindIdByCourse(ctx context.Context, course domain.Course) (int64, error)
+	// FindByCodeAndName 同一门课的不同老师，按老师排序
+	FindByCodeAndName(ctx context.Context, courseCode string, name string) ([]domain.Course, error)
+	// FindSiblings 课程号相同或课程名相同的课程，包括自己，课程号和课程名都相同的全部排在前面，其余的补到 limit
+	FindSiblings(ctx context.Context, courseCode string, name string, limit int64) ([]domain.Course, error)
 	Create(ctx context.Context, course domain.Course) error
 	Upsert(ctx context.Context, course domain.Course) error
 	FindIdByCourseWithoutUnknownProperty(ctx context.Context, course domain.Course) (int64, error)
+	List(ctx context.Context, filter domain.CourseFilter, curId int64, limit int64) ([]domain.Course, error)
+	Suggest(ctx context.Context, prefix string, limit int64) (domain.CourseSuggestion, error)
+	Search(ctx context.Context, keyword string, curScore float64, curId int64, limit int64) ([]domain.CourseSearchHit, error)
 }
 
 type CachedCourseRepository struct {
 	dao   dao.CourseDAO
 	cache cache.CourseCache
+	l     logger.Logger
 }
 
-func NewCachedCourseRepository(dao dao.CourseDAO, cache cache.CourseCache) CourseRepository {
-	return &CachedCourseRepository{dao: dao, cache: cache}
+func NewCachedCourseRepository(dao dao.CourseDAO, cache cache.CourseCache, l logger.Logger) CourseRepository {
+	return &CachedCourseRepository{dao: dao, cache: cache, l: l}
 }
 
 func (repo *CachedCourseRepository) Upsert(ctx context.Context, course domain.Course) error {
-	return repo.dao.Upsert(ctx, repo.ToEntity(course))
+	unknown, err := repo.dao.Upsert(ctx, repo.ToEntity(course))
+	if err != nil {
+		return err
+	}
+	repo.logUnknownSchools(unknown)
+	repo.delCache(ctx, course)
+	return nil
 }
 
 func (repo *CachedCourseRepository) FindIdByCourse(ctx context.Context, course domain.Course) (int64, error) {
@@ -42,27 +61,194 @@ func (repo *CachedCourseRepository) FindIdByCourse(ctx context.Context, course d
 	return repo.dao.FindIdByCourse(ctx, repo.ToEntity(course))
 }
 
+func (repo *CachedCourseRepository) FindByCodeAndName(ctx context.Context, courseCode string, name string) ([]domain.Course, error) {
+	courses, err := repo.dao.FindByCodeAndName(ctx, courseCode, name)
+	return slice.Map(courses, func(idx int, src dao.Course) domain.Course {
+		return repo.ToDomain(src)
+	}), err
+}
+
+func (repo *CachedCourseRepository) FindSiblings(ctx context.Context, courseCode string, name string,
+	limit int64) ([]domain.Course, error) {
+	courses, err := repo.dao.FindSiblings(ctx, courseCode, name, limit)
+	return slice.Map(courses, func(idx int, src dao.Course) domain.Course {
+		return repo.ToDomain(src)
+	}), err
+}
+
 func (repo *CachedCourseRepository) FindIdByCourseWithoutUnknownProperty(ctx context.Context, course domain.Course) (int64, error) {
 	return repo.dao.FindIdByCourseWithoutUnknownProperty(ctx, repo.ToEntity(course))
 }
 
 func (repo *CachedCourseRepository) Create(ctx context.Context, course domain.Course) error {
-	return repo.dao.Insert(ctx, repo.ToEntity(course))
+	unknown, err := repo.dao.Insert(ctx, repo.ToEntity(course))
+	if err != nil {
+		return err
+	}
+	repo.logUnknownSchools(unknown)
+	// 新建的课程可能之前被查过，留下了负缓存
+	repo.delCache(ctx, course)
+	return nil
 }
 
 func (repo *CachedCourseRepository) FindById(ctx context.Context, id int64) (domain.Course, error) {
-	// TODO 先查缓存，新发的课评会预热相关课程
+	// TODO 新发的课评会预热相关课程
 	res, err := repo.cache.Get(ctx, id)
-	if err == nil {
+	switch err {
+	case nil:
 		return res, nil
+	case cache.ErrCourseNotExist:
+		return domain.Course{}, ErrCourseNotFound
+	case cache.ErrKeyNotExist:
+	default:
+		// redis崩溃，这里预期没有缓存也撑得住，不采取降级来保护数据库
+		repo.l.Error("查询课程缓存失败", logger.Error(err), logger.Int64("courseId", id))
 	}
-	// 1. 没有key
-	// 2. redis崩溃，这里预期没有缓存也撑得住，不采取降级来保护数据库
 	c, err := repo.dao.FindById(ctx, id)
-	if err != nil {
+	switch err {
+	case nil:
+		res = repo.ToDomain(c)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			er := repo.cache.Set(ctx, res)
+			if er != nil {
+				repo.l.Error("回写课程缓存失败", logger.Error(er), logger.Int64("courseId", id))
+			}
+		}()
+		return res, nil
+	case ErrCourseNotFound:
+		// 不存在的 id 也缓存一下，免得被人拿着乱填的 id 一直打到数据库
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			er := repo.cache.SetNotExist(ctx, id)
+			if er != nil {
+				repo.l.Error("设置课程负缓存失败", logger.Error(er), logger.Int64("courseId", id))
+			}
+		}()
 		return domain.Course{}, err
+	default:
+		return domain.Course{}, err
+	}
+}
+
+func (repo *CachedCourseRepository) List(ctx context.Context, filter domain.CourseFilter, curId int64,
+	limit int64) ([]domain.Course, error) {
+	courses, err := repo.dao.List(ctx, dao.CourseFilter{
+		School:       filter.School,
+		DepartmentId: filter.DepartmentId,
+		Property:     int32(filter.Property),
+		Teacher:      filter.Teacher,
+		MinCredit:    filter.MinCredit,
+		MaxCredit:    filter.MaxCredit,
+	}, curId, limit)
+	return slice.Map(courses, func(idx int, src dao.Course) domain.Course {
+		return repo.ToDomain(src)
+	}), err
+}
+
+func (repo *CachedCourseRepository) Suggest(ctx context.Context, prefix string, limit int64) (domain.CourseSuggestion, error) {
+	names, teachers, err := repo.dao.Suggest(ctx, prefix, limit)
+	return domain.CourseSuggestion{
+		Names:    names,
+		Teachers: teachers,
+	}, err
+}
+
+func (repo *CachedCourseRepository) Search(ctx context.Context, keyword string, curScore float64, curId int64,
+	limit int64) ([]domain.CourseSearchHit, error) {
+	// 搜索的关键词太分散了，缓存命中率很低，直接走全文索引
+	res, err := repo.dao.Search(ctx, keyword, curScore, curId, limit)
+	return slice.Map(res, func(idx int, src dao.CourseWithScore) domain.CourseSearchHit {
+		return domain.CourseSearchHit{
+			Course: repo.ToDomain(src.Course),
+			Score:  src.Score,
+		}
+	}), err
+}
+
+func (repo *CachedCourseRepository) FindByIds(ctx context.Context, ids []int64) ([]domain.Course, error) {
+	if len(ids) == 0 {
+		return []domain.Course{}, nil
+	}
+	// 和 FindById 一样，redis 出错就当全部没命中，直接查数据库
+	hits, err := repo.cache.MGet(ctx, ids)
+	if err != nil {
+		repo.l.Error("批量查询课程缓存失败", logger.Error(err))
+		hits = map[int64]domain.Course{}
+	}
+	missIds := slice.FilterMap(ids, func(idx int, src int64) (int64, bool) {
+		_, ok := hits[src]
+		return src, !ok
+	})
+	if len(missIds) > 0 {
+		// 没命中的一次 IN 查询查完
+		cs, err := repo.dao.FindByIds(ctx, missIds)
+		if err != nil {
+			return nil, err
+		}
+		found := make([]domain.Course, 0, len(cs))
+		for _, c := range cs {
+			dc := repo.ToDomain(c)
+			hits[c.Id] = dc
+			found = append(found, dc)
+		}
+		go repo.backfill(found, missIds)
+	}
+	res := make([]domain.Course, 0, len(ids))
+	for _, id := range ids {
+		// Id 为 0 的是负缓存
+		if c, ok := hits[id]; ok && c.Id != 0 {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+// backfill 回写批量查询没命中的缓存，queried 里面没有查到的设置负缓存
+func (repo *CachedCourseRepository) backfill(found []domain.Course, queried []int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	foundIds := make(map[int64]struct{}, len(found))
+	for _, c := range found {
+		foundIds[c.Id] = struct{}{}
+		er := repo.cache.Set(ctx, c)
+		if er != nil {
+			repo.l.Error("回写课程缓存失败", logger.Error(er), logger.Int64("courseId", c.Id))
+		}
+	}
+	for _, id := range queried {
+		if _, ok := foundIds[id]; ok {
+			continue
+		}
+		er := repo.cache.SetNotExist(ctx, id)
+		if er != nil {
+			repo.l.Error("设置课程负缓存失败", logger.Error(er), logger.Int64("courseId", id))
+		}
+	}
+}
+
+// delCache 写数据库之后删缓存，调用方拿不到 id，只能按课程唯一索引查一次
+// 删缓存失败不影响写操作本身，只记录日志，缓存最终会过期
+func (repo *CachedCourseRepository) delCache(ctx context.Context, course domain.Course) {
+	id, err := repo.dao.FindIdByCourse(ctx, repo.ToEntity(course))
+	if err != nil {
+		repo.l.Error("删除课程缓存时查询课程id失败", logger.Error(err),
+			logger.String("courseCode", course.CourseCode))
+		return
+	}
+	err = repo.cache.Del(ctx, id)
+	if err != nil {
+		repo.l.Error("删除课程缓存失败", logger.Error(err), logger.Int64("courseId", id))
+	}
+}
+
+// logUnknownSchools 学院名对不上的课程照常写入，学院 id 为 0，需要管理员给学院补上别名
+func (repo *CachedCourseRepository) logUnknownSchools(schools []string) {
+	if len(schools) > 0 {
+		repo.l.Warn("课程的学院名没有对应的学院", logger.Any("schools", schools))
 	}
-	return repo.ToDomain(c), err
 }
 
 func (repo *CachedCourseRepository) ToEntity(course domain.Course) dao.Course {
@@ -79,12 +265,13 @@ func (repo *CachedCourseRepository) ToEntity(course domain.Course) dao.Course {
 
 func (repo *CachedCourseRepository) ToDomain(c dao.Course) domain.Course {
 	return domain.Course{
-		Id:         c.Id,
-		CourseCode: c.CourseCode,
-		Name:       c.Name,
-		Teacher:    c.Teacher,
-		School:     c.School,
-		Property:   coursev1.CourseProperty(c.Property),
-		Credit:     c.Credit,
+		Id:           c.Id,
+		CourseCode:   c.CourseCode,
+		Name:         c.Name,
+		Teacher:      c.Teacher,
+		School:       c.School,
+		Property:     coursev1.CourseProperty(c.Property),
+		Credit:       c.Credit,
+		DepartmentId: c.DepartmentId,
 	}
 }
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/ecodeclub/ekit/slice"
+	"time"
+)
+
+type CourseFavoriteRepository interface {
+	Create(ctx context.Context, uid int64, courseId int64) error
+	Delete(ctx context.Context, uid int64, courseId int64) error
+	// FindByUid 返回的课程只有 Id
+	FindByUid(ctx context.Context, uid int64, curId int64, limit int64) ([]domain.CourseFavorite, error)
+	Count(ctx context.Context, courseId int64) (int64, error)
+}
+
+type CachedCourseFavoriteRepository struct {
+	dao   dao.CourseFavoriteDAO
+	cache cache.CourseFavoriteCache
+	l     logger.Logger
+}
+
+func NewCachedCourseFavoriteRepository(dao dao.CourseFavoriteDAO, cache cache.CourseFavoriteCache,
+	l logger.Logger) CourseFavoriteRepository {
+	return &CachedCourseFavoriteRepository{dao: dao, cache: cache, l: l}
+}
+
+func (repo *CachedCourseFavoriteRepository) Create(ctx context.Context, uid int64, courseId int64) error {
+	ok, err := repo.dao.Insert(ctx, uid, courseId)
+	if err != nil || !ok {
+		return err
+	}
+	repo.delCountCache(courseId)
+	return nil
+}
+
+func (repo *CachedCourseFavoriteRepository) Delete(ctx context.Context, uid int64, courseId int64) error {
+	ok, err := repo.dao.Delete(ctx, uid, courseId)
+	if err != nil || !ok {
+		return err
+	}
+	repo.delCountCache(courseId)
+	return nil
+}
+
+func (repo *CachedCourseFavoriteRepository) delCountCache(courseId int64) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		er := repo.cache.DelCount(ctx, courseId)
+		if er != nil {
+			repo.l.Error("删除课程收藏数缓存失败", logger.Error(er), logger.Int64("courseId", courseId))
+		}
+	}()
+}
+
+func (repo *CachedCourseFavoriteRepository) FindByUid(ctx context.Context, uid int64, curId int64,
+	limit int64) ([]domain.CourseFavorite, error) {
+	fs, err := repo.dao.FindByUid(ctx, uid, curId, limit)
+	return slice.Map(fs, func(idx int, src dao.CourseFavorite) domain.CourseFavorite {
+		return domain.CourseFavorite{
+			Id:     src.Id,
+			Uid:    src.Uid,
+			Course: domain.Course{Id: src.CourseId},
+			Ctime:  time.UnixMilli(src.Ctime),
+		}
+	}), err
+}
+
+func (repo *CachedCourseFavoriteRepository) Count(ctx context.Context, courseId int64) (int64, error) {
+	cnt, err := repo.cache.GetCount(ctx, courseId)
+	if err == nil {
+		return cnt, nil
+	}
+	if err != cache.ErrKeyNotExist {
+		repo.l.Error("查询课程收藏数缓存失败", logger.Error(err), logger.Int64("courseId", courseId))
+	}
+	cnt, err = repo.dao.CountByCourseId(ctx, courseId)
+	if err != nil {
+		return 0, err
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		er := repo.cache.SetCount(ctx, courseId, cnt)
+		if er != nil {
+			repo.l.Error("回写课程收藏数缓存失败", logger.Error(er), logger.Int64("courseId", courseId))
+		}
+	}()
+	return cnt, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"time"
+)
+
+type CourseGradeRepository interface {
+	// FindSummariesByCourseIds 每门课按学年期汇总的成绩，攒够 step 条成绩变动才会更新，见 dao
+	// 缓存里的结果和 step 相关，step 来自配置，不会在运行时变
+	FindSummariesByCourseIds(ctx context.Context, cids []int64, step int64) ([]domain.SemesterGradeSummary, error)
+}
+
+type CachedCourseGradeRepository struct {
+	dao   dao.CourseGradeDAO
+	cache cache.CourseCache
+	l     logger.Logger
+}
+
+func NewCachedCourseGradeRepository(dao dao.CourseGradeDAO, cache cache.CourseCache, l logger.Logger) CourseGradeRepository {
+	return &CachedCourseGradeRepository{dao: dao, cache: cache, l: l}
+}
+
+func (repo *CachedCourseGradeRepository) FindSummariesByCourseIds(ctx context.Context,
+	cids []int64, step int64) ([]domain.SemesterGradeSummary, error) {
+	// 同名课程一般也就几个老师，逐个查缓存就行
+	res := make([]domain.SemesterGradeSummary, 0, len(cids))
+	missIds := make([]int64, 0, len(cids))
+	for _, cid := range cids {
+		summaries, err := repo.cache.GetGrades(ctx, cid)
+		if err == nil {
+			res = append(res, summaries...)
+			continue
+		}
+		if err != cache.ErrKeyNotExist {
+			repo.l.Error("查询课程成绩缓存失败", logger.Error(err), logger.Int64("courseId", cid))
+		}
+		missIds = append(missIds, cid)
+	}
+	if len(missIds) == 0 {
+		return res, nil
+	}
+	stats, err := repo.dao.FindStatsByCourseIds(ctx, missIds, step)
+	if err != nil {
+		return nil, err
+	}
+	byCourse := make(map[int64][]domain.SemesterGradeSummary, len(missIds))
+	for _, st := range stats {
+		semester, er := domain.ParseSemester(st.Year, st.Term)
+		if er != nil {
+			continue
+		}
+		g := domain.SemesterGradeSummary{
+			CourseId: st.CourseId,
+			Semester: semester,
+			Summary: domain.GradeSummary{
+				Count:     st.Cnt,
+				Sum:       st.Sum,
+				Histogram: [domain.GradeBucketCount]int64{st.Bucket0, st.Bucket1, st.Bucket2, st.Bucket3, st.Bucket4},
+			},
+		}
+		byCourse[g.CourseId] = append(byCourse[g.CourseId], g)
+		res = append(res, g)
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		// 没有成绩的课程也缓存一个空的，免得每次都去聚合
+		for _, cid := range missIds {
+			er := repo.cache.SetGrades(ctx, cid, byCourse[cid])
+			if er != nil {
+				repo.l.Error("回写课程成绩缓存失败", logger.Error(er), logger.Int64("courseId", cid))
+			}
+		}
+	}()
+	return res, nil
+}
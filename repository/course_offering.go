@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/ecodeclub/ekit/slice"
+)
+
+type CourseOfferingRepository interface {
+	BatchCreate(ctx context.Context, offerings []domain.CourseOffering) error
+	// SaveSessions 记录用户修读记录上的上课安排，没有上课安排的修读记录保留原有的
+	SaveSessions(ctx context.Context, uid int64, css []domain.CourseSubscription) error
+	// FindByCourseIds 返回的课程只有 Id，不保证顺序
+	FindByCourseIds(ctx context.Context, cids []int64) ([]domain.CourseOffering, error)
+	// FindSessions 用户自己修读的课在某个学年期的上课安排，没有上课安排的课程不在结果里
+	FindSessions(ctx context.Context, uid int64, cids []int64, semester domain.Semester) (map[int64][]domain.MeetingSession, error)
+	// FindCommonSessions 课程在某个学年期修读人数最多的那种上课安排，没有上课安排的课程不在结果里
+	FindCommonSessions(ctx context.Context, cids []int64, semester domain.Semester) (map[int64][]domain.MeetingSession, error)
+}
+
+type courseOfferingRepository struct {
+	dao dao.CourseOfferingDAO
+}
+
+func NewCourseOfferingRepository(dao dao.CourseOfferingDAO) CourseOfferingRepository {
+	return &courseOfferingRepository{dao: dao}
+}
+
+func (repo *courseOfferingRepository) BatchCreate(ctx context.Context, offerings []domain.CourseOffering) error {
+	return repo.dao.BatchUpsert(ctx, slice.Map(offerings, func(idx int, src domain.CourseOffering) dao.CourseOffering {
+		return dao.CourseOffering{
+			CourseId: src.Course.Id,
+			Year:     src.Semester.YearStr(),
+			Term:     src.Semester.TermStr(),
+		}
+	}))
+}
+
+func (repo *courseOfferingRepository) SaveSessions(ctx context.Context, uid int64, css []domain.CourseSubscription) error {
+	var sessions []dao.CourseSession
+	for _, cs := range css {
+		for _, s := range cs.Sessions {
+			sessions = append(sessions, dao.CourseSession{
+				Uid:         uid,
+				CourseId:    cs.Course.Id,
+				Year:        cs.Semester.YearStr(),
+				Term:        cs.Semester.TermStr(),
+				Weekday:     int8(s.Weekday),
+				StartPeriod: int8(s.StartPeriod),
+				EndPeriod:   int8(s.EndPeriod),
+				Weeks:       s.WeeksMask(),
+				Classroom:   s.Classroom,
+			})
+		}
+	}
+	return repo.dao.ReplaceSessions(ctx, sessions)
+}
+
+func (repo *courseOfferingRepository) FindSessions(ctx context.Context, uid int64, cids []int64,
+	semester domain.Semester) (map[int64][]domain.MeetingSession, error) {
+	ss, err := repo.dao.FindSessions(ctx, uid, cids, semester.YearStr(), semester.TermStr())
+	if err != nil {
+		return nil, err
+	}
+	return repo.toSessionMap(ss), nil
+}
+
+func (repo *courseOfferingRepository) FindCommonSessions(ctx context.Context, cids []int64,
+	semester domain.Semester) (map[int64][]domain.MeetingSession, error) {
+	ss, err := repo.dao.FindCommonSessions(ctx, cids, semester.YearStr(), semester.TermStr())
+	if err != nil {
+		return nil, err
+	}
+	return repo.toSessionMap(ss), nil
+}
+
+func (repo *courseOfferingRepository) toSessionMap(ss []dao.CourseSession) map[int64][]domain.MeetingSession {
+	res := make(map[int64][]domain.MeetingSession)
+	for _, s := range ss {
+		res[s.CourseId] = append(res[s.CourseId], domain.MeetingSession{
+			Weekday:     int(s.Weekday),
+			StartPeriod: int(s.StartPeriod),
+			EndPeriod:   int(s.EndPeriod),
+			Weeks:       domain.WeeksFromMask(s.Weeks),
+			Classroom:   s.Classroom,
+		})
+	}
+	return res
+}
+
+func (repo *courseOfferingRepository) FindByCourseIds(ctx context.Context, cids []int64) ([]domain.CourseOffering, error) {
+	os, err := repo.dao.FindByCourseIds(ctx, cids)
+	if err != nil {
+		return nil, err
+	}
+	return slice.FilterMap(os, func(idx int, src dao.CourseOffering) (domain.CourseOffering, bool) {
+		semester, er := domain.ParseSemester(src.Year, src.Term)
+		return domain.CourseOffering{
+			Course:   domain.Course{Id: src.CourseId},
+			Semester: semester,
+		}, er == nil && !semester.IsZero()
+	}), nil
+}
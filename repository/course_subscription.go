@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
 	"github.com/MuxiKeStack/be-course/domain"
 	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/repository/cache"
@@ -11,11 +12,22 @@ import (
 )
 
 type CourseSubscriptionRepository interface {
-	BatchCreateCourseSubscription(ctx context.Context, cs []domain.CourseSubscription) error
+	// BatchCreateCourseSubscription 返回这次新增的修读记录，重复消费的不算
+	BatchCreateCourseSubscription(ctx context.Context, cs []domain.CourseSubscription) ([]domain.CourseSubscription, error)
 	FindSubscriberUidsByCourseId(ctx context.Context, courseId int64, curUid int64, limit int64) ([]int64, error)
-	FindByUidYearTermAlive(ctx context.Context, uid int64, year string, term string,
+	// FindByUidSemesterAlive semester 为零值表示全部学年期
+	FindByUidSemesterAlive(ctx context.Context, uid int64, semester domain.Semester,
 		ttl time.Duration) ([]domain.CourseSubscription, error)
 	Subscribed(ctx context.Context, uid int64, courseId int64) (bool, error)
+	// FindByUidCourseId 同一门课修了好几次的话每个学年期一条，最近的学年期在前，没有修过返回空
+	FindByUidCourseId(ctx context.Context, uid int64, courseId int64) ([]domain.CourseSubscription, error)
+	// CountSubscribers 每门课按人去重的总人数，重修的只算一次，没有人修读的课程不在结果里
+	CountSubscribers(ctx context.Context, cids []int64) (map[int64]int64, error)
+	// FindSubscribedCourseIds 有人修读过的课程 id，升序分页
+	FindSubscribedCourseIds(ctx context.Context, curId int64, limit int64) ([]int64, error)
+	FindCoSubscriptions(ctx context.Context, cids []int64, minCount int64) ([]domain.CoSubscription, error)
+	CountSubscribersBySemester(ctx context.Context, semester domain.Semester) (map[int64]int64, error)
+	FindSubscriberStats(ctx context.Context, cid int64) (domain.SubscriberStats, error)
 }
 
 type CachedCourseSubscriptionRepository struct {
@@ -36,30 +48,34 @@ func (repo *CachedCourseSubscriptionRepository) Subscribed(ctx context.Context,
 	}
 }
 
+func (repo *CachedCourseSubscriptionRepository) FindByUidCourseId(ctx context.Context, uid int64,
+	courseId int64) ([]domain.CourseSubscription, error) {
+	css, err := repo.dao.FindByUidCourseId(ctx, uid, courseId)
+	return slice.Map(css, func(idx int, src dao.CourseSubscription) domain.CourseSubscription {
+		return repo.toDomain(src)
+	}), err
+}
+
 func NewCachedCourseSubscriptionRepository(dao dao.CourseSubscriptionDAO, cache cache.CourseSubscriptionCache,
 	l logger.Logger) CourseSubscriptionRepository {
 	return &CachedCourseSubscriptionRepository{dao: dao, cache: cache, l: l}
 }
 
-func (repo *CachedCourseSubscriptionRepository) FindByUidYearTermAlive(ctx context.Context, uid int64, year string, term string,
+func (repo *CachedCourseSubscriptionRepository) FindByUidSemesterAlive(ctx context.Context, uid int64, semester domain.Semester,
 	ttl time.Duration) ([]domain.CourseSubscription, error) {
-	css, err := repo.dao.FindByUidYearTermAlive(ctx, uid, year, term, ttl)
+	css, err := repo.dao.FindByUidYearTermAlive(ctx, uid, semester.YearStr(), semester.TermStr(), ttl)
 	return slice.Map(css, func(idx int, src dao.CourseSubscription) domain.CourseSubscription {
 		return repo.toDomain(src)
 	}), err
 }
 
-func (repo *CachedCourseSubscriptionRepository) BatchCreateCourseSubscription(ctx context.Context, cs []domain.CourseSubscription) error {
-	err := repo.dao.BatchInsertCourseSubscription(ctx, slice.Map(cs, func(idx int, src domain.CourseSubscription) dao.CourseSubscription {
-		return dao.CourseSubscription{
-			Uid:      src.Uid,
-			Year:     src.Year,
-			Term:     src.Term,
-			CourseId: src.Course.Id,
-		}
+func (repo *CachedCourseSubscriptionRepository) BatchCreateCourseSubscription(ctx context.Context,
+	cs []domain.CourseSubscription) ([]domain.CourseSubscription, error) {
+	inserted, err := repo.dao.BatchInsertCourseSubscription(ctx, slice.Map(cs, func(idx int, src domain.CourseSubscription) dao.CourseSubscription {
+		return repo.toEntity(src)
 	}))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -71,7 +87,74 @@ func (repo *CachedCourseSubscriptionRepository) BatchCreateCourseSubscription(ct
 			}
 		}
 	}()
-	return nil
+	return slice.Map(inserted, func(idx int, src dao.CourseSubscription) domain.CourseSubscription {
+		return repo.toDomain(src)
+	}), nil
+}
+
+func (repo *CachedCourseSubscriptionRepository) FindSubscriberStats(ctx context.Context, cid int64) (domain.SubscriberStats, error) {
+	counts, err := repo.dao.FindCountsByCourseId(ctx, cid)
+	if err != nil {
+		return domain.SubscriberStats{}, err
+	}
+	var res domain.SubscriberStats
+	for _, c := range counts {
+		semester, er := domain.ParseSemester(c.Year, c.Term)
+		if er != nil {
+			continue
+		}
+		if semester.IsZero() {
+			res.Total = c.Cnt
+			continue
+		}
+		res.BySemester = append(res.BySemester, domain.SemesterSubscriberCount{
+			Semester: semester,
+			Count:    c.Cnt,
+		})
+	}
+	return res, nil
+}
+
+func (repo *CachedCourseSubscriptionRepository) CountSubscribersBySemester(ctx context.Context,
+	semester domain.Semester) (map[int64]int64, error) {
+	counts, err := repo.dao.CountByYearTerm(ctx, semester.YearStr(), semester.TermStr())
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		res[c.CourseId] = c.Cnt
+	}
+	return res, nil
+}
+
+func (repo *CachedCourseSubscriptionRepository) CountSubscribers(ctx context.Context, cids []int64) (map[int64]int64, error) {
+	counts, err := repo.dao.CountByCourseIds(ctx, cids)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		res[c.CourseId] = c.Cnt
+	}
+	return res, nil
+}
+
+func (repo *CachedCourseSubscriptionRepository) FindSubscribedCourseIds(ctx context.Context, curId int64,
+	limit int64) ([]int64, error) {
+	return repo.dao.FindCourseIds(ctx, curId, limit)
+}
+
+func (repo *CachedCourseSubscriptionRepository) FindCoSubscriptions(ctx context.Context, cids []int64,
+	minCount int64) ([]domain.CoSubscription, error) {
+	cs, err := repo.dao.FindCoSubscriptions(ctx, cids, minCount)
+	return slice.Map(cs, func(idx int, src dao.CoSubscription) domain.CoSubscription {
+		return domain.CoSubscription{
+			CourseId:  src.CourseId,
+			RelatedId: src.RelatedId,
+			Count:     src.Cnt,
+		}
+	}), err
 }
 
 func (repo *CachedCourseSubscriptionRepository) FindSubscriberUidsByCourseId(ctx context.Context, courseId int64, curUid int64, limit int64) ([]int64, error) {
@@ -112,13 +195,44 @@ func (repo *CachedCourseSubscriptionRepository) FindSubscriberUidsByCourseId(ctx
 	return res, nil
 }
 
+func (repo *CachedCourseSubscriptionRepository) toEntity(cs domain.CourseSubscription) dao.CourseSubscription {
+	res := dao.CourseSubscription{
+		Uid:      cs.Uid,
+		Year:     cs.Semester.YearStr(),
+		Term:     cs.Semester.TermStr(),
+		CourseId: cs.Course.Id,
+	}
+	if cs.Grade != nil {
+		res.GradeRegular = cs.Grade.Grade.Regular
+		res.GradeFinal = cs.Grade.Grade.Final
+		res.GradeTotal = cs.Grade.Grade.Total
+		res.GradeSource = int32(cs.Grade.Source)
+		res.GradeFetchedAt = cs.Grade.FetchedAt.UnixMilli()
+	}
+	return res
+}
+
 func (repo *CachedCourseSubscriptionRepository) toDomain(cs dao.CourseSubscription) domain.CourseSubscription {
-	return domain.CourseSubscription{
+	// 入库前都是校验过的学年期
+	semester, _ := domain.ParseSemester(cs.Year, cs.Term)
+	res := domain.CourseSubscription{
 		Course: domain.Course{
 			Id: cs.CourseId,
 		},
-		Uid:  cs.Uid,
-		Year: cs.Year,
-		Term: cs.Term,
+		Uid:      cs.Uid,
+		Semester: semester,
+		Utime:    time.UnixMilli(cs.Utime),
+	}
+	if cs.GradeSource != 0 {
+		res.Grade = &domain.SubscriptionGrade{
+			Grade: domain.Grade{
+				Regular: cs.GradeRegular,
+				Final:   cs.GradeFinal,
+				Total:   cs.GradeTotal,
+			},
+			Source:    coursev1.GradeSource(cs.GradeSource),
+			FetchedAt: time.UnixMilli(cs.GradeFetchedAt),
+		}
 	}
+	return res
 }
@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type CalendarDAO interface {
+	Upsert(ctx context.Context, term AcademicTerm) error
+	FindAll(ctx context.Context) ([]AcademicTerm, error)
+}
+
+type GORMCalendarDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMCalendarDAO(db *gorm.DB) CalendarDAO {
+	return &GORMCalendarDAO{db: db}
+}
+
+func (dao *GORMCalendarDAO) Upsert(ctx context.Context, term AcademicTerm) error {
+	now := time.Now().UnixMilli()
+	term.Ctime = now
+	term.Utime = now
+	return dao.db.WithContext(ctx).Clauses(
+		clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+			"start_time":           term.StartTime,
+			"end_time":             term.EndTime,
+			"selection_start_time": term.SelectionStartTime,
+			"selection_end_time":   term.SelectionEndTime,
+			"utime":                now,
+		})}).Create(&term).Error
+}
+
+func (dao *GORMCalendarDAO) FindAll(ctx context.Context) ([]AcademicTerm, error) {
+	// 一年也就两三条，全部查出来
+	var terms []AcademicTerm
+	err := dao.db.WithContext(ctx).
+		Order("year asc, term asc").
+		Find(&terms).Error
+	return terms, err
+}
+
+type AcademicTerm struct {
+	Id   int64  `gorm:"primaryKey,autoIncrement"`
+	Year string `gorm:"uniqueIndex:year_term; type:char(4)"`
+	Term string `gorm:"uniqueIndex:year_term; type:char(1)"`
+	// 下面四个都是毫秒时间戳
+	StartTime          int64
+	EndTime            int64
+	SelectionStartTime int64
+	SelectionEndTime   int64
+	Utime              int64
+	Ctime              int64
+}
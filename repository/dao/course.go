@@ -3,9 +3,11 @@ package dao
 import (
 	"context"
 	"errors"
+	"github.com/MuxiKeStack/be-course/pkg/pinyinx"
 	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
@@ -18,13 +20,25 @@ type CourseDAO interface {
 	FindById(ctx context.Context, id int64) (Course, error)
 	FindByIds(ctx context.Context, cids []int64) ([]Course, error)
 	FindIdByCourse(ctx context.Context, course Course) (int64, error)
-	Insert(ctx context.Context, course Course) error
-	// BatchUpsert 这个实际上并未被上层的repository使用，而是被导入课程的脚本所使用的
+	// Insert、Upsert、BatchUpsert 都会返回没有对应学院的学院名，这些课程的学院 id 为 0
+	Insert(ctx context.Context, course Course) (unknownSchools []string, err error)
+	// BatchUpsert 这个实际上并未被上层的repository使用，而是被导入课程的脚本 cmd/import_course 所使用的
 	// 理论上每一个数据库只能由其微服务来调用，不能跨过服务直接调其数据库
 	// 但这里调用方是一个本地手动执行的脚本不是一个微服务，从实用性和效率上讲就这样写了
-	BatchUpsert(ctx context.Context, courses []Course) error
-	Upsert(ctx context.Context, course Course) error
+	BatchUpsert(ctx context.Context, courses []Course) (unknownSchools []string, err error)
+	Upsert(ctx context.Context, course Course) (unknownSchools []string, err error)
 	FindIdByCourseWithoutUnknownProperty(ctx context.Context, course Course) (int64, error)
+	// FindByCodeAndName 课程号和课程名都相同的课程，也就是同一门课的不同老师
+	FindByCodeAndName(ctx context.Context, courseCode string, name string) ([]Course, error)
+	// FindSiblings 课程号相同或课程名相同的课程，包括自己
+	// 课程号和课程名都相同的不受 limit 限制，全部排在前面，其余的按 id 升序补到 limit
+	FindSiblings(ctx context.Context, courseCode string, name string, limit int64) ([]Course, error)
+	// List 按条件筛选课程，按 id 升序，curId 为 0 时表示第一页
+	List(ctx context.Context, filter CourseFilter, curId int64, limit int64) ([]Course, error)
+	// Suggest 按汉字前缀、全拼前缀或首字母前缀联想课程名和老师名，prefix 需要事先转成小写
+	Suggest(ctx context.Context, prefix string, limit int64) (names []string, teachers []string, err error)
+	// Search 按相关度降序，相关度相同时按 id 降序，curId 为 0 时表示第一页
+	Search(ctx context.Context, keyword string, curScore float64, curId int64, limit int64) ([]CourseWithScore, error)
 }
 
 type GORMCourseDAO struct {
@@ -35,17 +49,57 @@ func NewGORMCourseDAO(db *gorm.DB) CourseDAO {
 	return &GORMCourseDAO{db: db}
 }
 
-func (dao *GORMCourseDAO) Upsert(ctx context.Context, course Course) error {
+func (dao *GORMCourseDAO) Upsert(ctx context.Context, course Course) ([]string, error) {
 	now := time.Now().UnixMilli()
 	course.Utime = now
 	course.Ctime = now
-	return dao.db.WithContext(ctx).Clauses(
-		clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
-			"property": course.Property,
-			"utime":    now,
-		})}).Create(&course).Error
+	fillPinyin(&course)
+	var unknown []string
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		courses := []Course{course}
+		var err error
+		unknown, err = resolveDepartments(tx, courses)
+		if err != nil {
+			return err
+		}
+		course = courses[0]
+		err = tx.Clauses(
+			clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+				"property":      course.Property,
+				"school":        upsertSchool,
+				"department_id": upsertDepartmentId,
+				"name_pinyin":   course.NamePinyin,
+				"name_initials": course.NameInitials,
+				"utime":         now,
+			})}).Create(&course).Error
+		if err != nil {
+			return err
+		}
+		return upsertTeacherLinks(tx, course)
+	})
+	return unknown, err
+}
+
+// 这次写入的学院名对不上学院时（department_id 为 0），不能把已经关联好的学院和规范名称冲掉，
+// 否则教务系统换个写法就会把管理员配好的课程解除关联
+var (
+	upsertDepartmentId = gorm.Expr("IF(VALUES(department_id) = 0, department_id, VALUES(department_id))")
+	upsertSchool       = gorm.Expr("IF(VALUES(department_id) = 0 AND department_id != 0, school, VALUES(school))")
+)
+
+// upsertTeacherLinks 冲突更新时拿不到已有课程的 id，要按唯一索引查一下
+func upsertTeacherLinks(tx *gorm.DB, course Course) error {
+	err := tx.Model(&Course{}).
+		Select("id").
+		Where("course_code = ? and name = ? and teacher = ?", course.CourseCode, course.Name, course.Teacher).
+		First(&course.Id).Error
+	if err != nil {
+		return err
+	}
+	return linkTeachers(tx, course.Id, course.Teacher)
 }
 
+// FindIdByCourse 课程的身份始终是 课程号+课程名+老师字段原文，拆分出来的老师只是关联，不参与识别
 func (dao *GORMCourseDAO) FindIdByCourse(ctx context.Context, course Course) (int64, error) {
 	var id int64
 	err := dao.db.WithContext(ctx).
@@ -68,11 +122,27 @@ func (dao *GORMCourseDAO) FindIdByCourseWithoutUnknownProperty(ctx context.Conte
 	return id, err
 }
 
-func (dao *GORMCourseDAO) Insert(ctx context.Context, course Course) error {
+func (dao *GORMCourseDAO) Insert(ctx context.Context, course Course) ([]string, error) {
 	now := time.Now().UnixMilli()
 	course.Ctime = now
 	course.Utime = now
-	return dao.db.WithContext(ctx).Create(&course).Error
+	fillPinyin(&course)
+	var unknown []string
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		courses := []Course{course}
+		var err error
+		unknown, err = resolveDepartments(tx, courses)
+		if err != nil {
+			return err
+		}
+		course = courses[0]
+		err = tx.Create(&course).Error
+		if err != nil {
+			return err
+		}
+		return linkTeachers(tx, course.Id, course.Teacher)
+	})
+	return unknown, err
 }
 
 func (dao *GORMCourseDAO) FindByIds(ctx context.Context, cids []int64) ([]Course, error) {
@@ -91,33 +161,196 @@ func (dao *GORMCourseDAO) FindById(ctx context.Context, id int64) (Course, error
 	return c, err
 }
 
-func (dao *GORMCourseDAO) BatchUpsert(ctx context.Context, courses []Course) error {
+func (dao *GORMCourseDAO) BatchUpsert(ctx context.Context, courses []Course) ([]string, error) {
 	now := time.Now().UnixMilli()
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var eg errgroup.Group
-		for _, c := range courses {
+	var unknown []string
+	// 一个事务只有一个连接，不能在里面并发，全部顺序执行：先一次查完学院，再写课程，最后统一补老师关联
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		unknown, err = resolveDepartments(tx, courses)
+		if err != nil {
+			return err
+		}
+		for i := range courses {
+			c := courses[i]
 			c.Ctime = now
 			c.Utime = now
-			eg.Go(func() error {
-				return tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
-					"utime": now,
-				})}).Create(&c).Error
-			})
+			fillPinyin(&c)
+			// 顺便把老数据的拼音和学院也补上
+			err = tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+				"school":        upsertSchool,
+				"department_id": upsertDepartmentId,
+				"name_pinyin":   c.NamePinyin,
+				"name_initials": c.NameInitials,
+				"utime":         now,
+			})}).Create(&c).Error
+			if err != nil {
+				return err
+			}
 		}
-		return eg.Wait()
+		for _, c := range courses {
+			err = upsertTeacherLinks(tx, c)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return unknown, err
+}
+
+func (dao *GORMCourseDAO) FindByCodeAndName(ctx context.Context, courseCode string, name string) ([]Course, error) {
+	// 正好是唯一索引 courseCode_name_teacher 的前缀
+	var courses []Course
+	err := dao.db.WithContext(ctx).
+		Where("course_code = ? and name = ?", courseCode, name).
+		Order("teacher asc").
+		Find(&courses).Error
+	return courses, err
+}
+
+func (dao *GORMCourseDAO) FindSiblings(ctx context.Context, courseCode string, name string, limit int64) ([]Course, error) {
+	// 同一门课的不同老师是最重要的，先单独查出来，不能被其他课挤掉
+	courses, err := dao.FindByCodeAndName(ctx, courseCode, name)
+	if err != nil || int64(len(courses)) >= limit {
+		return courses, err
+	}
+	// course_code 走唯一索引 courseCode_name_teacher 的前缀，name 走 idx_name，MySQL 会 index merge
+	var others []Course
+	err = dao.db.WithContext(ctx).
+		Where("(course_code = ? or name = ?) and not (course_code = ? and name = ?)", courseCode, name, courseCode, name).
+		Order("id asc").
+		Limit(int(limit) - len(courses)).
+		Find(&others).Error
+	return append(courses, others...), err
+}
+
+func (dao *GORMCourseDAO) List(ctx context.Context, filter CourseFilter, curId int64, limit int64) ([]Course, error) {
+	query := dao.db.WithContext(ctx).Where("id > ?", curId)
+	// 等值条件放前面，学分是范围条件，放在联合索引的最后
+	if filter.School != "" {
+		query = query.Where("school = ?", filter.School)
+	}
+	if filter.DepartmentId != 0 {
+		query = query.Where("department_id = ?", filter.DepartmentId)
+	}
+	if filter.Property != 0 {
+		query = query.Where("property = ?", filter.Property)
+	}
+	if filter.Teacher != "" {
+		// 老师字段可能有多个老师，按拆分后的老师关联筛选
+		query = query.Where("id IN (?)", dao.db.Model(&CourseTeacher{}).
+			Select("course_teachers.course_id").
+			Joins("JOIN teachers ON teachers.id = course_teachers.teacher_id").
+			Where("teachers.name = ?", filter.Teacher))
+	}
+	if filter.MinCredit > 0 {
+		query = query.Where("credit >= ?", filter.MinCredit)
+	}
+	if filter.MaxCredit > 0 {
+		query = query.Where("credit <= ?", filter.MaxCredit)
+	}
+	var courses []Course
+	err := query.Order("id asc").
+		Limit(int(limit)).
+		Find(&courses).Error
+	return courses, err
+}
+
+func (dao *GORMCourseDAO) Suggest(ctx context.Context, prefix string, limit int64) ([]string, []string, error) {
+	like := escapeLike(prefix) + "%"
+	nameQuery := dao.db.WithContext(ctx).Model(&Course{}).Distinct("name")
+	// 课程的老师字段可能是 "张三,李四"，老师要从拆分后的老师表里联想
+	teacherQuery := dao.db.WithContext(ctx).Model(&Teacher{})
+	if pinyinx.ContainsHan(prefix) {
+		nameQuery = nameQuery.Where("name LIKE ?", like)
+		teacherQuery = teacherQuery.Where("name LIKE ?", like)
+	} else {
+		// 全拼和首字母分别有索引，MySQL 可以 index merge
+		nameQuery = nameQuery.Where("name_pinyin LIKE ? OR name_initials LIKE ?", like, like)
+		teacherQuery = teacherQuery.Where("name_pinyin LIKE ? OR name_initials LIKE ?", like, like)
+	}
+	var (
+		eg       errgroup.Group
+		names    []string
+		teachers []string
+	)
+	eg.Go(func() error {
+		return nameQuery.Order("name").Limit(int(limit)).Pluck("name", &names).Error
+	})
+	eg.Go(func() error {
+		return teacherQuery.Order("name").Limit(int(limit)).Pluck("name", &teachers).Error
 	})
+	return names, teachers, eg.Wait()
+}
+
+// fillPinyin 根据课程名生成拼音，所有写入课程的地方都要调用，保证拼音和原文一致
+// 老师的拼音在 linkTeachers 里按拆分后的老师生成
+func fillPinyin(c *Course) {
+	c.NamePinyin = pinyinx.Full(c.Name)
+	c.NameInitials = pinyinx.Initials(c.Name)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+// searchMatchExpr 必须与 Course 上 idx_course_search 全文索引的列完全一致，否则 MySQL 不会走全文索引
+const searchMatchExpr = "MATCH(course_code, name, teacher, school) AGAINST(? IN NATURAL LANGUAGE MODE)"
+
+func (dao *GORMCourseDAO) Search(ctx context.Context, keyword string, curScore float64, curId int64,
+	limit int64) ([]CourseWithScore, error) {
+	query := dao.db.WithContext(ctx).
+		Model(&Course{}).
+		Select("*, "+searchMatchExpr+" AS score", keyword).
+		Where(searchMatchExpr, keyword)
+	if curId > 0 {
+		// 相关度用到了整个表的 IDF，导入课程或者 OPTIMIZE 之后会变，所以翻页是近似的：
+		// 两页之间相关度变了的话，可能漏掉或者重复几条，调用方要按 id 去重
+		query = query.Where(searchMatchExpr+" < ? OR ("+searchMatchExpr+" = ? AND id < ?)",
+			keyword, curScore, keyword, curScore, curId)
+	}
+	var res []CourseWithScore
+	err := query.Order("score desc, id desc").
+		Limit(int(limit)).
+		Scan(&res).Error
+	return res, err
+}
+
+// CourseFilter 零值的字段表示不按该字段筛选
+type CourseFilter struct {
+	School       string
+	DepartmentId int64
+	Property     int32
+	Teacher      string
+	MinCredit    float64
+	MaxCredit    float64
+}
+
+type CourseWithScore struct {
+	Course
+	Score float64
 }
 
 type Course struct {
 	Id int64 `gorm:"primaryKey,autoIncrement"`
 	// 这里是否有必要为property建立一个包含四个字段的联合索引
 	// 下面三个字段的长度需要修改，等数据相对稳定了再往短了改
-	CourseCode string `gorm:"uniqueIndex:courseCode_name_teacher; index:idx_code_name_teacher_property; type:char(30)"`
-	Name       string `gorm:"uniqueIndex:courseCode_name_teacher; index:idx_code_name_teacher_property; type:varchar(100)"`
-	Teacher    string `gorm:"uniqueIndex:courseCode_name_teacher; index:idx_code_name_teacher_property; type:varchar(100)"`
-	Property   int32  `gorm:"index:idx_code_name_teacher_property"`
-	School     string
-	Credit     float64
-	Ctime      int64
-	Utime      int64
+	// idx_name 和 idx_teacher 是给汉字前缀联想用的
+	// idx_course_search 是搜索用的全文索引，中文没有空格分词，所以要用 ngram 解析器
+	CourseCode string `gorm:"uniqueIndex:courseCode_name_teacher; index:idx_code_name_teacher_property; index:idx_course_search,class:FULLTEXT,option:WITH PARSER ngram; type:char(30)"`
+	Name       string `gorm:"uniqueIndex:courseCode_name_teacher; index:idx_code_name_teacher_property; index:idx_name; index:idx_course_search,class:FULLTEXT,option:WITH PARSER ngram; type:varchar(100)"`
+	Teacher    string `gorm:"uniqueIndex:courseCode_name_teacher; index:idx_code_name_teacher_property; index:idx_teacher; index:idx_course_search,class:FULLTEXT,option:WITH PARSER ngram; type:varchar(100)"`
+	// idx_school_property_credit 和 idx_property_credit 是给课程列表筛选用的，
+	// 按学院、课程性质筛选是最常见的，只按课程性质筛选的话（比如全部通核课）走后一个
+	Property int32   `gorm:"index:idx_code_name_teacher_property; index:idx_school_property_credit,priority:2; index:idx_property_credit,priority:1"`
+	School   string  `gorm:"index:idx_school_property_credit,priority:1; index:idx_course_search,class:FULLTEXT,option:WITH PARSER ngram; type:varchar(100)"`
+	Credit   float64 `gorm:"index:idx_school_property_credit,priority:3; index:idx_property_credit,priority:2"`
+	// School 写入时会被换成学院的规范名称
+	DepartmentId int64 `gorm:"index"`
+	// 下面两个是联想用的拼音，由课程名生成，只做前缀匹配
+	NamePinyin   string `gorm:"index; type:varchar(255)"`
+	NameInitials string `gorm:"index; type:varchar(100)"`
+	Ctime        int64
+	Utime        int64
 }
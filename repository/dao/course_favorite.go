@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type CourseFavoriteDAO interface {
+	// Insert 已经收藏过的返回 false
+	Insert(ctx context.Context, uid int64, courseId int64) (bool, error)
+	// Delete 没有收藏过的返回 false
+	Delete(ctx context.Context, uid int64, courseId int64) (bool, error)
+	// FindByUid 按收藏的先后倒序，curId 为 0 表示第一页
+	FindByUid(ctx context.Context, uid int64, curId int64, limit int64) ([]CourseFavorite, error)
+	CountByCourseId(ctx context.Context, courseId int64) (int64, error)
+}
+
+type GORMCourseFavoriteDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMCourseFavoriteDAO(db *gorm.DB) CourseFavoriteDAO {
+	return &GORMCourseFavoriteDAO{db: db}
+}
+
+func (dao *GORMCourseFavoriteDAO) Insert(ctx context.Context, uid int64, courseId int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&CourseFavorite{
+		Uid:      uid,
+		CourseId: courseId,
+		Ctime:    time.Now().UnixMilli(),
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMCourseFavoriteDAO) Delete(ctx context.Context, uid int64, courseId int64) (bool, error) {
+	res := dao.db.WithContext(ctx).
+		Where("uid = ? and course_id = ?", uid, courseId).
+		Delete(&CourseFavorite{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMCourseFavoriteDAO) FindByUid(ctx context.Context, uid int64, curId int64,
+	limit int64) ([]CourseFavorite, error) {
+	query := dao.db.WithContext(ctx).
+		Where("uid = ?", uid)
+	if curId > 0 {
+		query = query.Where("id < ?", curId)
+	}
+	var res []CourseFavorite
+	err := query.Order("id desc").
+		Limit(int(limit)).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMCourseFavoriteDAO) CountByCourseId(ctx context.Context, courseId int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).
+		Model(&CourseFavorite{}).
+		Where("course_id = ?", courseId).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+type CourseFavorite struct {
+	Id       int64 `gorm:"primaryKey,autoIncrement"`
+	Uid      int64 `gorm:"uniqueIndex:uid_courseId"`
+	CourseId int64 `gorm:"uniqueIndex:uid_courseId; index"`
+	Ctime    int64
+}
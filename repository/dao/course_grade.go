@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// CourseGradeDAO 成绩本身只存在修读记录上（见 CourseSubscription.GradeTotal），这里只存统计的快照
+type CourseGradeDAO interface {
+	// FindStatsByCourseIds 每门课每个学年期的成绩统计快照，上次快照之后有 step 条及以上的成绩变动（新成绩或者更正）
+	// 才按修读记录重新统计，这样对外的统计只会一批批地变，前后两次的差值推不出单个人的成绩
+	// 有成绩的人数不到 step 的学年期不会有快照
+	FindStatsByCourseIds(ctx context.Context, cids []int64, step int64) ([]CourseGradeStat, error)
+}
+
+type GORMCourseGradeDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMCourseGradeDAO(db *gorm.DB) CourseGradeDAO {
+	return &GORMCourseGradeDAO{db: db}
+}
+
+// gradeBucketSums 各分数段的人数，与 domain.GradeBucketCount 的分段保持一致
+const gradeBucketSums = "SUM(s.grade_total < 60) AS bucket0, " +
+	"SUM(s.grade_total >= 60 AND s.grade_total < 70) AS bucket1, " +
+	"SUM(s.grade_total >= 70 AND s.grade_total < 80) AS bucket2, " +
+	"SUM(s.grade_total >= 80 AND s.grade_total < 90) AS bucket3, " +
+	"SUM(s.grade_total >= 90) AS bucket4"
+
+func (dao *GORMCourseGradeDAO) FindStatsByCourseIds(ctx context.Context, cids []int64, step int64) ([]CourseGradeStat, error) {
+	var res []CourseGradeStat
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 按修读记录现在的成绩统计一遍，顺便数一下上次快照之后变动过的成绩有多少条
+		var lives []liveCourseGradeStat
+		err := tx.Table("course_subscriptions AS s").
+			Select("s.course_id, s.year, s.term, COUNT(*) AS cnt, SUM(s.grade_total) AS sum, "+gradeBucketSums+", "+
+				"MAX(s.grade_changed_at) AS changed_at, SUM(s.grade_changed_at > COALESCE(g.changed_at, 0)) AS changes").
+			Joins("LEFT JOIN course_grade_stats AS g ON g.course_id = s.course_id AND g.year = s.year AND g.term = s.term").
+			Where("s.course_id in ? and s.grade_source <> 0", cids).
+			Group("s.course_id, s.year, s.term").
+			Scan(&lives).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now().UnixMilli()
+		var stale []CourseGradeStat
+		for _, l := range lives {
+			if l.Changes < step {
+				continue
+			}
+			l.Ctime = now
+			l.Utime = now
+			stale = append(stale, l.CourseGradeStat)
+		}
+		if len(stale) > 0 {
+			err = tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{
+				"cnt", "sum", "bucket0", "bucket1", "bucket2", "bucket3", "bucket4", "changed_at", "utime",
+			})}).Create(&stale).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Where("course_id in ?", cids).Find(&res).Error
+	})
+	return res, err
+}
+
+type liveCourseGradeStat struct {
+	CourseGradeStat
+	Changes int64
+}
+
+// CourseGradeStat 某门课某个学年期对外展示的成绩统计，是某个时刻修读记录上成绩的汇总，不是实时的
+type CourseGradeStat struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	CourseId int64  `gorm:"uniqueIndex:courseId_year_term"`
+	Year     string `gorm:"uniqueIndex:courseId_year_term; type:char(4)"`
+	Term     string `gorm:"uniqueIndex:courseId_year_term; type:char(1)"`
+	Cnt      int64
+	Sum      float64
+	// 各分数段的人数，分段见 gradeBucketSums
+	Bucket0 int64
+	Bucket1 int64
+	Bucket2 int64
+	Bucket3 int64
+	Bucket4 int64
+	// ChangedAt 计入这次快照的成绩里最晚的变动时间，之后变动的成绩攒够一批才重新统计
+	ChangedAt int64
+	Utime     int64
+	Ctime     int64
+}
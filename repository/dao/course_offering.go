@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type CourseOfferingDAO interface {
+	// BatchUpsert 除了查课程列表时顺带写入，导入课程的脚本 cmd/import_course 也会调用，理由同 CourseDAO.BatchUpsert
+	// 两边都不记录还在选课的学年期，选课结束之前课程还可能被取消
+	BatchUpsert(ctx context.Context, offerings []CourseOffering) error
+	// ReplaceSessions 按修读记录（uid、课程、学年期）整个替换上课安排，没有带上课安排的修读记录保留原有的
+	ReplaceSessions(ctx context.Context, sessions []CourseSession) error
+	FindByCourseIds(ctx context.Context, cids []int64) ([]CourseOffering, error)
+	// FindSessions 用户自己修读记录上的上课安排
+	FindSessions(ctx context.Context, uid int64, cids []int64, year string, term string) ([]CourseSession, error)
+	// FindCommonSessions 每门课取修读人数最多的那种上课安排，给用户自己还没有修读记录的课用
+	FindCommonSessions(ctx context.Context, cids []int64, year string, term string) ([]CourseSession, error)
+}
+
+type GORMCourseOfferingDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMCourseOfferingDAO(db *gorm.DB) CourseOfferingDAO {
+	return &GORMCourseOfferingDAO{db: db}
+}
+
+func (dao *GORMCourseOfferingDAO) BatchUpsert(ctx context.Context, offerings []CourseOffering) error {
+	if len(offerings) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	for i := range offerings {
+		offerings[i].Utime = now
+		offerings[i].Ctime = now
+	}
+	// 每次查课程列表都会写一遍，绝大部分都是已经有的，只更新 utime
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+		"utime": now,
+	})}).Create(&offerings).Error
+}
+
+func (dao *GORMCourseOfferingDAO) ReplaceSessions(ctx context.Context, sessions []CourseSession) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 教务系统返回的是一条修读记录完整的上课安排，直接删了重建
+		type subscriptionKey struct {
+			uid      int64
+			courseId int64
+			year     string
+			term     string
+		}
+		replaced := make(map[subscriptionKey]struct{})
+		for i, s := range sessions {
+			sessions[i].Ctime = now
+			k := subscriptionKey{uid: s.Uid, courseId: s.CourseId, year: s.Year, term: s.Term}
+			if _, ok := replaced[k]; ok {
+				continue
+			}
+			replaced[k] = struct{}{}
+			err := tx.Where("uid = ? and year = ? and term = ? and course_id = ?", k.uid, k.year, k.term, k.courseId).
+				Delete(&CourseSession{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(&sessions).Error
+	})
+}
+
+func (dao *GORMCourseOfferingDAO) FindByCourseIds(ctx context.Context, cids []int64) ([]CourseOffering, error) {
+	var res []CourseOffering
+	err := dao.db.WithContext(ctx).
+		Where("course_id in ?", cids).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMCourseOfferingDAO) FindSessions(ctx context.Context, uid int64, cids []int64, year string,
+	term string) ([]CourseSession, error) {
+	// 走 uid_year_term 索引，一个人一学期也就几十条
+	var res []CourseSession
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? and year = ? and term = ? and course_id in ?", uid, year, term, cids).
+		Order("weekday asc, start_period asc").
+		Find(&res).Error
+	return res, err
+}
+
+// sessionArrangement 一种上课安排（也就是一个教学班）有多少人
+type sessionArrangement struct {
+	CourseId int64
+	Uid      int64
+	Cnt      int64
+}
+
+func (dao *GORMCourseOfferingDAO) FindCommonSessions(ctx context.Context, cids []int64, year string,
+	term string) ([]CourseSession, error) {
+	if len(cids) == 0 {
+		return nil, nil
+	}
+	// 把每条修读记录的上课安排拼成一个字符串，字符串相同就是同一个教学班，走 courseId_year_term 索引
+	arrangements := dao.db.WithContext(ctx).
+		Model(&CourseSession{}).
+		Select("course_id, uid, GROUP_CONCAT(CONCAT_WS(',', weekday, start_period, end_period, weeks, classroom) "+
+			"ORDER BY weekday, start_period, weeks, classroom SEPARATOR ';') AS arrangement").
+		Where("course_id in ? and year = ? and term = ?", cids, year, term).
+		Group("course_id, uid")
+	var as []sessionArrangement
+	err := dao.db.WithContext(ctx).
+		Table("(?) AS a", arrangements).
+		Select("course_id, MIN(uid) AS uid, COUNT(*) AS cnt").
+		Group("course_id, arrangement").
+		Scan(&as).Error
+	if err != nil || len(as) == 0 {
+		return nil, err
+	}
+	// 每门课取人数最多的，人数一样的取 uid 小的，保证每次结果一样
+	best := make(map[int64]sessionArrangement, len(cids))
+	for _, a := range as {
+		b, ok := best[a.CourseId]
+		if !ok || a.Cnt > b.Cnt || (a.Cnt == b.Cnt && a.Uid < b.Uid) {
+			best[a.CourseId] = a
+		}
+	}
+	pairs := make([][]any, 0, len(best))
+	for _, b := range best {
+		pairs = append(pairs, []any{b.CourseId, b.Uid})
+	}
+	var res []CourseSession
+	err = dao.db.WithContext(ctx).
+		Where("year = ? and term = ? and (course_id, uid) in ?", year, term, pairs).
+		Order("weekday asc, start_period asc").
+		Find(&res).Error
+	return res, err
+}
+
+// backfillCourseOfferings 开课记录是后加的，建表的时候从已有的修读记录里补一遍
+func backfillCourseOfferings(db *gorm.DB) error {
+	now := time.Now().UnixMilli()
+	return db.Exec("INSERT IGNORE INTO course_offerings (course_id, year, term, utime, ctime) "+
+		"SELECT DISTINCT course_id, year, term, ?, ? FROM course_subscriptions", now, now).Error
+}
+
+type CourseOffering struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	CourseId int64  `gorm:"uniqueIndex:courseId_year_term"`
+	Year     string `gorm:"uniqueIndex:courseId_year_term; type:char(4)"`
+	Term     string `gorm:"uniqueIndex:courseId_year_term; type:char(1)"`
+	Utime    int64
+	Ctime    int64
+}
+
+// CourseSession 修读记录的上课安排，一条修读记录可以有多条，比如一周上两次
+// 同一门课（同一个老师）的不同教学班上课时间不一样，所以跟着修读记录（uid、课程、学年期）存，不跟着开课记录
+type CourseSession struct {
+	Id          int64  `gorm:"primaryKey,autoIncrement"`
+	Uid         int64  `gorm:"index:uid_year_term"`
+	CourseId    int64  `gorm:"index:courseId_year_term"`
+	Year        string `gorm:"index:uid_year_term; index:courseId_year_term; type:char(4)"`
+	Term        string `gorm:"index:uid_year_term; index:courseId_year_term; type:char(1)"`
+	Weekday     int8
+	StartPeriod int8
+	EndPeriod   int8
+	// Weeks 第 i 位表示第 i 周上课
+	Weeks     int64
+	Classroom string `gorm:"type:varchar(100)"`
+	Ctime     int64
+}
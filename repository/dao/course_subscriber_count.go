@@ -0,0 +1,123 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"time"
+)
+
+// CourseSubscriberCount 每门课每个学年期的修读人数，Year 和 Term 都为空的是总人数（同一个人重修只算一次）
+// 只在插入新的修读记录时增量维护，见 incrSubscriberCounts
+type CourseSubscriberCount struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	CourseId int64  `gorm:"uniqueIndex:courseId_year_term"`
+	Year     string `gorm:"uniqueIndex:courseId_year_term; index:year_term; type:char(4)"`
+	Term     string `gorm:"uniqueIndex:courseId_year_term; index:year_term; type:char(1)"`
+	Cnt      int64
+	Utime    int64
+	Ctime    int64
+}
+
+type subscriberCountKey struct {
+	courseId int64
+	year     string
+	term     string
+}
+
+type uidCourseKey struct {
+	uid      int64
+	courseId int64
+}
+
+// incrSubscriberCounts 要在插入修读记录的同一个事务里调用，inserted 必须是这次新插入的行，要带上 id
+func incrSubscriberCounts(tx *gorm.DB, inserted []CourseSubscription) error {
+	if len(inserted) == 0 {
+		return nil
+	}
+	deltas := make(map[subscriberCountKey]int64, len(inserted)*2)
+	// 同一批里可能有同一个人同一门课的多个学年期（重修），总人数最多加一
+	batchIds := make(map[uidCourseKey][]int64, len(inserted))
+	for _, s := range inserted {
+		deltas[subscriberCountKey{courseId: s.CourseId, year: s.Year, term: s.Term}]++
+		k := uidCourseKey{uid: s.Uid, courseId: s.CourseId}
+		batchIds[k] = append(batchIds[k], s.Id)
+	}
+	// 加锁的读和计数行的更新都按固定顺序来，并发的两批碰到同样的热门课时加锁顺序一致，不会互相死锁
+	keys := make([]uidCourseKey, 0, len(batchIds))
+	for k := range batchIds {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].uid != keys[j].uid {
+			return keys[i].uid < keys[j].uid
+		}
+		return keys[i].courseId < keys[j].courseId
+	})
+	for _, k := range keys {
+		ids := batchIds[k]
+		// 走 uid_courseId 索引，这一批之外没有修读记录，说明这个人第一次修这门课，总人数才加一
+		// 加锁读：别的事务正在给这个人插入这门课的话要等它提交，不然两边都看不到对方，总人数会加两次
+		var cnt int64
+		err := tx.Model(&CourseSubscription{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ? and course_id = ? and id not in ?", k.uid, k.courseId, ids).
+			Count(&cnt).Error
+		if err != nil {
+			return err
+		}
+		if cnt == 0 {
+			deltas[subscriberCountKey{courseId: k.courseId}]++
+		}
+	}
+	countKeys := make([]subscriberCountKey, 0, len(deltas))
+	for k := range deltas {
+		countKeys = append(countKeys, k)
+	}
+	sort.Slice(countKeys, func(i, j int) bool {
+		a, b := countKeys[i], countKeys[j]
+		if a.courseId != b.courseId {
+			return a.courseId < b.courseId
+		}
+		if a.year != b.year {
+			return a.year < b.year
+		}
+		return a.term < b.term
+	})
+	now := time.Now().UnixMilli()
+	for _, k := range countKeys {
+		delta := deltas[k]
+		err := tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+			"cnt":   gorm.Expr("cnt + ?", delta),
+			"utime": now,
+		})}).Create(&CourseSubscriberCount{
+			CourseId: k.courseId,
+			Year:     k.year,
+			Term:     k.term,
+			Cnt:      delta,
+			Utime:    now,
+			Ctime:    now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillSubscriberCounts 计数是后加的，建表的时候从已有的修读记录里算一遍
+// 多个实例同时启动，或者建表之后已经有增量写进来的话，计数行已经存在，直接用重新算的结果覆盖
+func backfillSubscriberCounts(db *gorm.DB) error {
+	now := time.Now().UnixMilli()
+	err := db.Exec("INSERT INTO course_subscriber_counts (course_id, year, term, cnt, utime, ctime) "+
+		"SELECT course_id, year, term, COUNT(*), ?, ? FROM course_subscriptions GROUP BY course_id, year, term "+
+		"ON DUPLICATE KEY UPDATE cnt = VALUES(cnt), utime = VALUES(utime)",
+		now, now).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec("INSERT INTO course_subscriber_counts (course_id, year, term, cnt, utime, ctime) "+
+		"SELECT course_id, '', '', COUNT(DISTINCT uid), ?, ? FROM course_subscriptions GROUP BY course_id "+
+		"ON DUPLICATE KEY UPDATE cnt = VALUES(cnt), utime = VALUES(utime)",
+		now, now).Error
+}
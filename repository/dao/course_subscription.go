@@ -2,17 +2,34 @@ package dao
 
 import (
 	"context"
-	"golang.org/x/sync/errgroup"
+	"errors"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"slices"
+	"sort"
 	"time"
 )
 
 type CourseSubscriptionDAO interface {
-	BatchInsertCourseSubscription(ctx context.Context, subscriptions []CourseSubscription) error
+	// BatchInsertCourseSubscription 返回这次新插入的行，已经存在的只更新
+	BatchInsertCourseSubscription(ctx context.Context, subscriptions []CourseSubscription) ([]CourseSubscription, error)
 	FindSubscriberUidsByCourseId(ctx context.Context, courseId int64, curUid int64, limit int64) ([]int64, error)
 	FindByUidYearTermAlive(ctx context.Context, uid int64, year string, term string, ttl time.Duration) ([]CourseSubscription, error)
+	// GetSubscriptionInfo 重修过的课返回最近一个学年期的
 	GetSubscriptionInfo(ctx context.Context, uid int64, courseId int64) (CourseSubscription, error)
+	// FindByUidCourseId 重修过的课每个学年期一条，最近的学年期在前
+	FindByUidCourseId(ctx context.Context, uid int64, courseId int64) ([]CourseSubscription, error)
+	// CountByCourseIds 每门课的总人数
+	CountByCourseIds(ctx context.Context, cids []int64) ([]CourseSubscriberCount, error)
+	// FindCountsByCourseId 一门课每个学年期的人数和总人数
+	FindCountsByCourseId(ctx context.Context, cid int64) ([]CourseSubscriberCount, error)
+	// FindCourseIds 有人修读过的课程 id，升序，给离线任务分批遍历用
+	FindCourseIds(ctx context.Context, curId int64, limit int64) ([]int64, error)
+	// FindCoSubscriptions 修过 cids 中某门课的人还修过哪些课，人数少于 minCount 的不返回
+	FindCoSubscriptions(ctx context.Context, cids []int64, minCount int64) ([]CoSubscription, error)
+	// CountByYearTerm 某个学年期每门课的修读人数
+	CountByYearTerm(ctx context.Context, year string, term string) ([]CourseSubscriberCount, error)
 }
 
 type GORMCourseSubscriptionDAO struct {
@@ -23,30 +40,102 @@ func (dao *GORMCourseSubscriptionDAO) GetSubscriptionInfo(ctx context.Context, u
 	var cs CourseSubscription
 	err := dao.db.WithContext(ctx).
 		Where("uid = ? and course_id = ?", uid, courseId).
+		Order("year desc, term desc").
 		First(&cs).Error
 	return cs, err
 }
 
+func (dao *GORMCourseSubscriptionDAO) FindByUidCourseId(ctx context.Context, uid int64, courseId int64) ([]CourseSubscription, error) {
+	var css []CourseSubscription
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? and course_id = ?", uid, courseId).
+		Order("year desc, term desc").
+		Find(&css).Error
+	return css, err
+}
+
 func NewGORMCourseSubscriptionDAO(db *gorm.DB) CourseSubscriptionDAO {
 	return &GORMCourseSubscriptionDAO{db: db}
 }
 
-func (dao *GORMCourseSubscriptionDAO) BatchInsertCourseSubscription(ctx context.Context, subscriptions []CourseSubscription) error {
+func (dao *GORMCourseSubscriptionDAO) BatchInsertCourseSubscription(ctx context.Context,
+	subscriptions []CourseSubscription) ([]CourseSubscription, error) {
 	now := time.Now().UnixMilli()
-	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var eg errgroup.Group
+	// 按唯一索引的顺序插入，和计数行一样，并发的两批加锁顺序一致
+	subscriptions = slices.Clone(subscriptions)
+	sort.Slice(subscriptions, func(i, j int) bool {
+		a, b := subscriptions[i], subscriptions[j]
+		if a.Uid != b.Uid {
+			return a.Uid < b.Uid
+		}
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		if a.Term != b.Term {
+			return a.Term < b.Term
+		}
+		return a.CourseId < b.CourseId
+	})
+	var inserted []CourseSubscription
+	var err error
+	// 顺序固定之后还是可能碰上间隙锁的死锁，InnoDB 会回滚整个事务，重试几次，不然这一批修读记录就丢了
+	for i := 0; i < maxDeadlockRetries; i++ {
+		inserted, err = dao.batchInsert(ctx, subscriptions, now)
+		if !isDeadlock(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+func (dao *GORMCourseSubscriptionDAO) batchInsert(ctx context.Context, subscriptions []CourseSubscription,
+	now int64) ([]CourseSubscription, error) {
+	var inserted []CourseSubscription
+	// 一个事务只有一个连接，不能在里面并发，而且要按行拿影响的行数，顺序执行
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		for _, s := range subscriptions {
-			eg.Go(func() error {
-				s.Utime = now
-				s.Ctime = now
-				return tx.Clauses(
-					clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
-						"utime": now,
-					})}).Create(&s).Error
-			})
+			s.Utime = now
+			s.Ctime = now
+			updates := clause.Set{{Column: clause.Column{Name: "utime"}, Value: now}}
+			// 这次没有拿到成绩的话不能把之前的成绩覆盖掉
+			if s.GradeSource != 0 {
+				s.GradeChangedAt = now
+				// ON DUPLICATE KEY UPDATE 按顺序赋值，grade_changed_at 要在 grade_total 被覆盖之前和旧值比较
+				updates = append(updates, clause.Assignment{Column: clause.Column{Name: "grade_changed_at"},
+					Value: gorm.Expr("IF(grade_source = 0 OR grade_total <> VALUES(grade_total), VALUES(grade_changed_at), grade_changed_at)")})
+				updates = append(updates, clause.Assignments(map[string]any{
+					"grade_regular":    s.GradeRegular,
+					"grade_final":      s.GradeFinal,
+					"grade_total":      s.GradeTotal,
+					"grade_source":     s.GradeSource,
+					"grade_fetched_at": s.GradeFetchedAt,
+				})...)
+			}
+			res := tx.Clauses(
+				clause.OnConflict{DoUpdates: updates}).Create(&s)
+			if res.Error != nil {
+				return res.Error
+			}
+			// MySQL 的 ON DUPLICATE KEY UPDATE，插入影响 1 行，更新影响 2 行，utime 每次都变，不会是 0
+			// DSN 开了 clientFoundRows 的话更新也是 1 行，InitDB 里会拦住这种配置
+			if res.RowsAffected == 1 {
+				inserted = append(inserted, s)
+			}
 		}
-		return eg.Wait()
+		return incrSubscriberCounts(tx, inserted)
 	})
+	return inserted, err
+}
+
+const maxDeadlockRetries = 3
+
+// isDeadlock 1213 是 InnoDB 检测到死锁之后回滚事务的错误码
+func isDeadlock(err error) bool {
+	var me *mysqldriver.MySQLError
+	return errors.As(err, &me) && me.Number == 1213
 }
 
 func (dao *GORMCourseSubscriptionDAO) FindByUidYearTermAlive(ctx context.Context, uid int64, year string,
@@ -59,13 +148,75 @@ func (dao *GORMCourseSubscriptionDAO) FindByUidYearTermAlive(ctx context.Context
 	if term != "" {
 		query = query.Where("term = ?", term)
 	}
-	// 这个就不加入索引了，上面已经过滤到很少的数据了
-	query = query.Where("utime > ?", time.Now().Add(-TTL).UnixMilli())
+	// 这个就不加入索引了，上面已经过滤到很少的数据了，TTL 为负数表示永不过期
+	if TTL >= 0 {
+		query = query.Where("utime > ?", time.Now().Add(-TTL).UnixMilli())
+	}
 	var cs []CourseSubscription
 	err := query.Find(&cs).Error
 	return cs, err
 }
 
+func (dao *GORMCourseSubscriptionDAO) CountByCourseIds(ctx context.Context, cids []int64) ([]CourseSubscriberCount, error) {
+	var res []CourseSubscriberCount
+	err := dao.db.WithContext(ctx).
+		Where("course_id in ? and year = '' and term = ''", cids).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMCourseSubscriptionDAO) FindCountsByCourseId(ctx context.Context, cid int64) ([]CourseSubscriberCount, error) {
+	var res []CourseSubscriberCount
+	err := dao.db.WithContext(ctx).
+		Where("course_id = ?", cid).
+		Order("year asc, term asc").
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMCourseSubscriptionDAO) FindCourseIds(ctx context.Context, curId int64, limit int64) ([]int64, error) {
+	var cids []int64
+	err := dao.db.WithContext(ctx).
+		Model(&CourseSubscription{}).
+		Distinct("course_id").
+		Where("course_id > ?", curId).
+		Order("course_id asc").
+		Limit(int(limit)).
+		Pluck("course_id", &cids).Error
+	return cids, err
+}
+
+func (dao *GORMCourseSubscriptionDAO) FindCoSubscriptions(ctx context.Context, cids []int64,
+	minCount int64) ([]CoSubscription, error) {
+	// a 走 courseId_uid，b 走 uid_courseId，都是覆盖索引
+	// 同一个人不同学年期修同一门课（重修）只算一次
+	var res []CoSubscription
+	err := dao.db.WithContext(ctx).
+		Table("course_subscriptions AS a").
+		Select("a.course_id AS course_id, b.course_id AS related_id, COUNT(DISTINCT a.uid) AS cnt").
+		Joins("JOIN course_subscriptions AS b ON a.uid = b.uid AND a.course_id <> b.course_id").
+		Where("a.course_id in ?", cids).
+		Group("a.course_id, b.course_id").
+		Having("cnt >= ?", minCount).
+		Scan(&res).Error
+	return res, err
+}
+
+func (dao *GORMCourseSubscriptionDAO) CountByYearTerm(ctx context.Context, year string,
+	term string) ([]CourseSubscriberCount, error) {
+	var res []CourseSubscriberCount
+	err := dao.db.WithContext(ctx).
+		Where("year = ? and term = ?", year, term).
+		Find(&res).Error
+	return res, err
+}
+
+type CoSubscription struct {
+	CourseId  int64
+	RelatedId int64
+	Cnt       int64
+}
+
 func (dao *GORMCourseSubscriptionDAO) FindSubscriberUidsByCourseId(ctx context.Context, courseId int64,
 	curUid int64, limit int64) ([]int64, error) {
 	var uids []int64
@@ -81,15 +232,23 @@ func (dao *GORMCourseSubscriptionDAO) FindSubscriberUidsByCourseId(ctx context.C
 	return uids, err
 }
 
-// TODO 设计索引 <courseId,uid>
 type CourseSubscription struct {
 	Id int64 `gorm:"primaryKey,autoIncrement"`
 	// 下面四个是高频查询字段，需要设置索引加速查询，作为前缀
-	Uid  int64  `gorm:"uniqueIndex:uid_year_term_courseId; index:uid_courseId"`
+	// courseId_uid 给按课程查修读者用
+	Uid  int64  `gorm:"uniqueIndex:uid_year_term_courseId; index:uid_courseId; index:courseId_uid,priority:2"`
 	Year string `gorm:"uniqueIndex:uid_year_term_courseId; type:char(4)"`
 	Term string `gorm:"uniqueIndex:uid_year_term_courseId; type:char(1)"`
 	// course_id 和其他字段组合的结果需要时唯一的，所以要放在尾部
-	CourseId int64 `gorm:"uniqueIndex:uid_year_term_courseId; index:uid_courseId"`
-	Utime    int64 // 这里历史查询条件，但是特地为utime建立索引感觉没太大必要，因为前面的条件已经把大多数行筛掉了
-	Ctime    int64
+	CourseId int64 `gorm:"uniqueIndex:uid_year_term_courseId; index:uid_courseId; index:courseId_uid,priority:1"`
+	// 成绩相关，GradeSource 为 0 表示没有成绩，成绩只存在这里，统计见 CourseGradeDAO
+	GradeRegular   float64
+	GradeFinal     float64
+	GradeTotal     float64
+	GradeSource    int32
+	GradeFetchedAt int64
+	// GradeChangedAt 总评第一次拿到或者被更正的时间，重复爬到一样的成绩不变，统计按它判断攒没攒够一批变动
+	GradeChangedAt int64
+	Utime          int64 // 这里历史查询条件，但是特地为utime建立索引感觉没太大必要，因为前面的条件已经把大多数行筛掉了
+	Ctime          int64
 }
@@ -0,0 +1,244 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ecodeclub/ekit/slice"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrDepartmentAliasConflict = errors.New("学院别名已属于其他学院")
+
+type DepartmentDAO interface {
+	FindAll(ctx context.Context) ([]Department, error)
+	FindAllAliases(ctx context.Context) ([]DepartmentAlias, error)
+	CountCourses(ctx context.Context) ([]DepartmentCourseCount, error)
+	// Save id 为 0 时新建，改名时会同步课程上的学院名，返回新建的 id 和学院名被修改的课程
+	Save(ctx context.Context, d Department, aliases []string) (int64, []int64, error)
+	// Merge 把 fromId 合并到 toId，别名和课程都归到 toId 下，返回学院被修改的课程
+	Merge(ctx context.Context, fromId int64, toId int64) ([]int64, error)
+}
+
+type GORMDepartmentDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMDepartmentDAO(db *gorm.DB) DepartmentDAO {
+	return &GORMDepartmentDAO{db: db}
+}
+
+func (dao *GORMDepartmentDAO) FindAll(ctx context.Context) ([]Department, error) {
+	var ds []Department
+	err := dao.db.WithContext(ctx).Order("id asc").Find(&ds).Error
+	return ds, err
+}
+
+func (dao *GORMDepartmentDAO) FindAllAliases(ctx context.Context) ([]DepartmentAlias, error) {
+	var as []DepartmentAlias
+	err := dao.db.WithContext(ctx).Order("id asc").Find(&as).Error
+	return as, err
+}
+
+func (dao *GORMDepartmentDAO) CountCourses(ctx context.Context) ([]DepartmentCourseCount, error) {
+	var res []DepartmentCourseCount
+	err := dao.db.WithContext(ctx).
+		Model(&Course{}).
+		Select("department_id, COUNT(*) AS cnt").
+		Where("department_id > 0").
+		Group("department_id").
+		Scan(&res).Error
+	return res, err
+}
+
+func (dao *GORMDepartmentDAO) Save(ctx context.Context, d Department, aliases []string) (int64, []int64, error) {
+	now := time.Now().UnixMilli()
+	var cids []int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		d.Utime = now
+		if d.Id == 0 {
+			d.Ctime = now
+			if err := tx.Create(&d).Error; err != nil {
+				return err
+			}
+		} else {
+			var old Department
+			if err := tx.Where("id = ?", d.Id).First(&old).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&Department{}).Where("id = ?", d.Id).Updates(map[string]any{
+				"code":  d.Code,
+				"name":  d.Name,
+				"utime": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if old.Name != d.Name {
+				// 旧名字也留作别名，之后教务系统再传旧名字过来也能对上
+				aliases = append(aliases, old.Name)
+				if err = tx.Model(&Course{}).Where("department_id = ?", d.Id).Pluck("id", &cids).Error; err != nil {
+					return err
+				}
+				err = tx.Model(&Course{}).Where("department_id = ?", d.Id).Updates(map[string]any{
+					"school": d.Name,
+					"utime":  now,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		aliases = append(aliases, d.Name)
+		if err := addDepartmentAliases(tx, d.Id, aliases, now); err != nil {
+			return err
+		}
+		// 之前学院名对不上的课程，补上别名之后就归到这个学院
+		var unlinked []int64
+		err := tx.Model(&Course{}).Where("department_id = 0 and school in ?", aliases).Pluck("id", &unlinked).Error
+		if err != nil || len(unlinked) == 0 {
+			return err
+		}
+		cids = append(cids, unlinked...)
+		return tx.Model(&Course{}).Where("id in ?", unlinked).Updates(map[string]any{
+			"department_id": d.Id,
+			"school":        d.Name,
+			"utime":         now,
+		}).Error
+	})
+	return d.Id, cids, err
+}
+
+func addDepartmentAliases(tx *gorm.DB, did int64, aliases []string, now int64) error {
+	for _, alias := range aliases {
+		if alias == "" {
+			continue
+		}
+		var existing DepartmentAlias
+		err := tx.Where("alias = ?", alias).First(&existing).Error
+		switch {
+		case err == nil:
+			if existing.DepartmentId != did {
+				// 别名要换学院的话走合并，不然两个学院的课程就对不上了
+				return ErrDepartmentAliasConflict
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = tx.Create(&DepartmentAlias{Alias: alias, DepartmentId: did, Ctime: now}).Error
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (dao *GORMDepartmentDAO) Merge(ctx context.Context, fromId int64, toId int64) ([]int64, error) {
+	now := time.Now().UnixMilli()
+	var cids []int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var from, to Department
+		if err := tx.Where("id = ?", fromId).First(&from).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", toId).First(&to).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Course{}).Where("department_id = ?", fromId).Pluck("id", &cids).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&Course{}).Where("department_id = ?", fromId).Updates(map[string]any{
+			"department_id": toId,
+			"school":        to.Name,
+			"utime":         now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&DepartmentAlias{}).Where("department_id = ?", fromId).
+			Update("department_id", toId).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&Department{}, fromId).Error
+	})
+	return cids, err
+}
+
+// resolveDepartments 按教务系统给的学院名找到学院，并把课程的学院名改成规范名称
+// 只查不建，对不上的课程学院 id 为 0、学院名保持原样，返回这些学院名，由管理员补别名或者合并
+// 要在写课程之前一次查完，不要在每门课的写入里各自去查
+func resolveDepartments(tx *gorm.DB, courses []Course) ([]string, error) {
+	schools := make([]string, 0, len(courses))
+	for _, c := range courses {
+		if c.School != "" {
+			schools = append(schools, c.School)
+		}
+	}
+	if len(schools) == 0 {
+		return nil, nil
+	}
+	var aliases []DepartmentAlias
+	if err := tx.Where("alias in ?", schools).Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	byAlias := make(map[string]Department, len(aliases))
+	if len(aliases) > 0 {
+		var ds []Department
+		err := tx.Where("id in ?", slice.Map(aliases, func(idx int, src DepartmentAlias) int64 {
+			return src.DepartmentId
+		})).Find(&ds).Error
+		if err != nil {
+			return nil, err
+		}
+		byId := make(map[int64]Department, len(ds))
+		for _, d := range ds {
+			byId[d.Id] = d
+		}
+		for _, a := range aliases {
+			if d, ok := byId[a.DepartmentId]; ok {
+				byAlias[a.Alias] = d
+			}
+		}
+	}
+	var unknown []string
+	seen := make(map[string]struct{})
+	for i := range courses {
+		d, ok := byAlias[courses[i].School]
+		if ok {
+			courses[i].DepartmentId = d.Id
+			courses[i].School = d.Name
+			continue
+		}
+		courses[i].DepartmentId = 0
+		if _, ok = seen[courses[i].School]; !ok && courses[i].School != "" {
+			seen[courses[i].School] = struct{}{}
+			unknown = append(unknown, courses[i].School)
+		}
+	}
+	return unknown, nil
+}
+
+type DepartmentCourseCount struct {
+	DepartmentId int64
+	Cnt          int64
+}
+
+type Department struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 没填代码的学院存 NULL，NULL 不受唯一索引限制
+	Code  sql.NullString `gorm:"uniqueIndex; type:varchar(20)"`
+	Name  string         `gorm:"uniqueIndex; type:varchar(100)"`
+	Utime int64
+	Ctime int64
+}
+
+// DepartmentAlias 学院的各种写法，规范名称本身也是一个别名，这样查一次就能找到学院
+type DepartmentAlias struct {
+	Id           int64  `gorm:"primaryKey,autoIncrement"`
+	Alias        string `gorm:"uniqueIndex; type:varchar(100)"`
+	DepartmentId int64  `gorm:"index"`
+	Ctime        int64
+}
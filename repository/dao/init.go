@@ -3,7 +3,35 @@ package dao
 import "gorm.io/gorm"
 
 func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(
+	backfillOfferings := !db.Migrator().HasTable(&CourseOffering{})
+	backfillCounts := !db.Migrator().HasTable(&CourseSubscriberCount{})
+	err := db.AutoMigrate(
 		&Course{},
-		&CourseSubscription{})
+		&CourseSubscription{},
+		&AcademicTerm{},
+		&CourseGradeStat{},
+		&Teacher{},
+		&CourseTeacher{},
+		&Department{},
+		&DepartmentAlias{},
+		&CourseOffering{},
+		&CourseSession{},
+		&CourseSubscriberCount{},
+		&ProgramPlan{},
+		&PlanCategory{},
+		&PlanRequiredCourse{},
+		&PlannedCourse{},
+		&CourseFavorite{})
+	if err != nil {
+		return err
+	}
+	if backfillOfferings {
+		if err = backfillCourseOfferings(db); err != nil {
+			return err
+		}
+	}
+	if backfillCounts {
+		return backfillSubscriberCounts(db)
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type PlannedCourseDAO interface {
+	// Insert 已经在计划里的课不会报错
+	Insert(ctx context.Context, pc PlannedCourse) error
+	Delete(ctx context.Context, uid int64, year string, term string, courseId int64) error
+	FindByUidYearTerm(ctx context.Context, uid int64, year string, term string) ([]PlannedCourse, error)
+	CountByUidYearTerm(ctx context.Context, uid int64, year string, term string) (int64, error)
+}
+
+type GORMPlannedCourseDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMPlannedCourseDAO(db *gorm.DB) PlannedCourseDAO {
+	return &GORMPlannedCourseDAO{db: db}
+}
+
+func (dao *GORMPlannedCourseDAO) Insert(ctx context.Context, pc PlannedCourse) error {
+	now := time.Now().UnixMilli()
+	pc.Utime = now
+	pc.Ctime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+		"utime": now,
+	})}).Create(&pc).Error
+}
+
+func (dao *GORMPlannedCourseDAO) Delete(ctx context.Context, uid int64, year string, term string, courseId int64) error {
+	return dao.db.WithContext(ctx).
+		Where("uid = ? and year = ? and term = ? and course_id = ?", uid, year, term, courseId).
+		Delete(&PlannedCourse{}).Error
+}
+
+func (dao *GORMPlannedCourseDAO) FindByUidYearTerm(ctx context.Context, uid int64, year string,
+	term string) ([]PlannedCourse, error) {
+	var res []PlannedCourse
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? and year = ? and term = ?", uid, year, term).
+		Order("ctime asc, id asc").
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMPlannedCourseDAO) CountByUidYearTerm(ctx context.Context, uid int64, year string,
+	term string) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).
+		Model(&PlannedCourse{}).
+		Where("uid = ? and year = ? and term = ?", uid, year, term).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+type PlannedCourse struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Uid      int64  `gorm:"uniqueIndex:uid_year_term_courseId"`
+	Year     string `gorm:"uniqueIndex:uid_year_term_courseId; type:char(4)"`
+	Term     string `gorm:"uniqueIndex:uid_year_term_courseId; type:char(1)"`
+	CourseId int64  `gorm:"uniqueIndex:uid_year_term_courseId"`
+	Utime    int64
+	Ctime    int64
+}
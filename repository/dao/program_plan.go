@@ -0,0 +1,118 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type ProgramPlanDAO interface {
+	// Save 按专业和入学年份覆盖整个培养方案，给导入培养方案的命令用
+	Save(ctx context.Context, plan ProgramPlan, categories []PlanCategory, courses []PlanRequiredCourse) error
+	FindByMajorAndEntryYear(ctx context.Context, major string, entryYear string) (ProgramPlan, error)
+	FindCategories(ctx context.Context, planId int64) ([]PlanCategory, error)
+	FindRequiredCourses(ctx context.Context, planId int64) ([]PlanRequiredCourse, error)
+}
+
+type GORMProgramPlanDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMProgramPlanDAO(db *gorm.DB) ProgramPlanDAO {
+	return &GORMProgramPlanDAO{db: db}
+}
+
+func (dao *GORMProgramPlanDAO) Save(ctx context.Context, plan ProgramPlan, categories []PlanCategory,
+	courses []PlanRequiredCourse) error {
+	now := time.Now().UnixMilli()
+	plan.Utime = now
+	plan.Ctime = now
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]any{
+			"total_credits": plan.TotalCredits,
+			"utime":         now,
+		})}).Create(&plan).Error
+		if err != nil {
+			return err
+		}
+		// 冲突更新时拿不到 id
+		err = tx.Model(&ProgramPlan{}).Select("id").
+			Where("major = ? and entry_year = ?", plan.Major, plan.EntryYear).
+			First(&plan.Id).Error
+		if err != nil {
+			return err
+		}
+		// 培养方案是整体导入的，子项直接删了重建
+		if err = tx.Where("plan_id = ?", plan.Id).Delete(&PlanCategory{}).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("plan_id = ?", plan.Id).Delete(&PlanRequiredCourse{}).Error; err != nil {
+			return err
+		}
+		for i := range categories {
+			categories[i].PlanId = plan.Id
+		}
+		for i := range courses {
+			courses[i].PlanId = plan.Id
+		}
+		if len(categories) > 0 {
+			if err = tx.Create(&categories).Error; err != nil {
+				return err
+			}
+		}
+		if len(courses) > 0 {
+			return tx.Create(&courses).Error
+		}
+		return nil
+	})
+}
+
+func (dao *GORMProgramPlanDAO) FindByMajorAndEntryYear(ctx context.Context, major string, entryYear string) (ProgramPlan, error) {
+	var p ProgramPlan
+	err := dao.db.WithContext(ctx).
+		Where("major = ? and entry_year = ?", major, entryYear).
+		First(&p).Error
+	return p, err
+}
+
+func (dao *GORMProgramPlanDAO) FindCategories(ctx context.Context, planId int64) ([]PlanCategory, error) {
+	var res []PlanCategory
+	err := dao.db.WithContext(ctx).
+		Where("plan_id = ?", planId).
+		Order("property asc").
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMProgramPlanDAO) FindRequiredCourses(ctx context.Context, planId int64) ([]PlanRequiredCourse, error) {
+	var res []PlanRequiredCourse
+	err := dao.db.WithContext(ctx).
+		Where("plan_id = ?", planId).
+		Order("id asc").
+		Find(&res).Error
+	return res, err
+}
+
+type ProgramPlan struct {
+	Id           int64  `gorm:"primaryKey,autoIncrement"`
+	Major        string `gorm:"uniqueIndex:major_entryYear; type:varchar(100)"`
+	EntryYear    string `gorm:"uniqueIndex:major_entryYear; type:char(4)"`
+	TotalCredits float64
+	Utime        int64
+	Ctime        int64
+}
+
+type PlanCategory struct {
+	Id         int64 `gorm:"primaryKey,autoIncrement"`
+	PlanId     int64 `gorm:"uniqueIndex:planId_property"`
+	Property   int32 `gorm:"uniqueIndex:planId_property"`
+	MinCredits float64
+}
+
+type PlanRequiredCourse struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	PlanId     int64  `gorm:"uniqueIndex:planId_courseCode"`
+	CourseCode string `gorm:"uniqueIndex:planId_courseCode; type:char(30)"`
+	Name       string `gorm:"type:varchar(100)"`
+}
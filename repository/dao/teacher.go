@@ -0,0 +1,124 @@
+package dao
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/pkg/pinyinx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+type TeacherDAO interface {
+	FindById(ctx context.Context, id int64) (Teacher, error)
+	FindByName(ctx context.Context, name string) (Teacher, error)
+	FindCourseIdsByTeacherId(ctx context.Context, tid int64) ([]int64, error)
+}
+
+type GORMTeacherDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMTeacherDAO(db *gorm.DB) TeacherDAO {
+	return &GORMTeacherDAO{db: db}
+}
+
+func (dao *GORMTeacherDAO) FindById(ctx context.Context, id int64) (Teacher, error) {
+	var t Teacher
+	err := dao.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&t).Error
+	return t, err
+}
+
+func (dao *GORMTeacherDAO) FindByName(ctx context.Context, name string) (Teacher, error) {
+	var t Teacher
+	err := dao.db.WithContext(ctx).
+		Where("name = ?", name).
+		First(&t).Error
+	return t, err
+}
+
+func (dao *GORMTeacherDAO) FindCourseIdsByTeacherId(ctx context.Context, tid int64) ([]int64, error) {
+	var cids []int64
+	err := dao.db.WithContext(ctx).
+		Model(&CourseTeacher{}).
+		Where("teacher_id = ?", tid).
+		Order("course_id asc").
+		Pluck("course_id", &cids).Error
+	return cids, err
+}
+
+// teacherSeparator 教务系统里多个老师的写法不统一，见过的分隔符都算上
+var teacherSeparator = strings.NewReplacer("，", ",", "、", ",", ";", ",", "；", ",", "/", ",")
+
+// splitTeachers 把 "张三,李四" 这样的老师字段拆开，去掉空白和重复
+func splitTeachers(teacher string) []string {
+	parts := strings.Split(teacherSeparator.Replace(teacher), ",")
+	res := make([]string, 0, len(parts))
+	seen := make(map[string]struct{}, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if _, ok := seen[p]; ok || p == "" {
+			continue
+		}
+		seen[p] = struct{}{}
+		res = append(res, p)
+	}
+	return res
+}
+
+// linkTeachers 拆分课程的老师字段，建立课程和老师的关联，要和写课程在同一个事务里
+// 只增不删，课程的老师字段本身是唯一索引的一部分，不会变
+func linkTeachers(tx *gorm.DB, courseId int64, teacher string) error {
+	names := splitTeachers(teacher)
+	if len(names) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	teachers := make([]Teacher, 0, len(names))
+	for _, name := range names {
+		teachers = append(teachers, Teacher{
+			Name:         name,
+			NamePinyin:   pinyinx.Full(name),
+			NameInitials: pinyinx.Initials(name),
+			Utime:        now,
+			Ctime:        now,
+		})
+	}
+	// 顺便给之前建的老师补上拼音
+	err := tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"name_pinyin", "name_initials"})}).
+		Create(&teachers).Error
+	if err != nil {
+		return err
+	}
+	// 冲突的行拿不到 id，统一再查一遍
+	var tids []int64
+	err = tx.Model(&Teacher{}).Where("name in ?", names).Pluck("id", &tids).Error
+	if err != nil {
+		return err
+	}
+	links := make([]CourseTeacher, 0, len(tids))
+	for _, tid := range tids {
+		links = append(links, CourseTeacher{CourseId: courseId, TeacherId: tid, Ctime: now})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+type Teacher struct {
+	Id   int64  `gorm:"primaryKey,autoIncrement"`
+	Name string `gorm:"uniqueIndex; type:varchar(50)"`
+	// 联想用的拼音，只做前缀匹配
+	NamePinyin   string `gorm:"index; type:varchar(255)"`
+	NameInitials string `gorm:"index; type:varchar(100)"`
+	Utime        int64
+	Ctime        int64
+}
+
+// CourseTeacher 课程和老师多对多，按课程查老师走唯一索引的前缀，按老师查课程走 teacherId 索引
+type CourseTeacher struct {
+	Id        int64 `gorm:"primaryKey,autoIncrement"`
+	CourseId  int64 `gorm:"uniqueIndex:courseId_teacherId"`
+	TeacherId int64 `gorm:"uniqueIndex:courseId_teacherId; index"`
+	Ctime     int64
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"time"
+)
+
+var ErrDepartmentAliasConflict = dao.ErrDepartmentAliasConflict
+
+type DepartmentRepository interface {
+	// FindAllWithCourseCount 学院不多，一次全部返回，按 id 升序
+	FindAllWithCourseCount(ctx context.Context) ([]domain.DepartmentSummary, error)
+	Save(ctx context.Context, d domain.Department) (int64, error)
+	Merge(ctx context.Context, fromId int64, toId int64) error
+}
+
+// CachedDepartmentRepository 学院本身不缓存，这里的缓存是改了学院名之后要删掉的课程缓存
+type CachedDepartmentRepository struct {
+	dao         dao.DepartmentDAO
+	courseCache cache.CourseCache
+	l           logger.Logger
+}
+
+func NewCachedDepartmentRepository(dao dao.DepartmentDAO, courseCache cache.CourseCache,
+	l logger.Logger) DepartmentRepository {
+	return &CachedDepartmentRepository{dao: dao, courseCache: courseCache, l: l}
+}
+
+func (repo *CachedDepartmentRepository) FindAllWithCourseCount(ctx context.Context) ([]domain.DepartmentSummary, error) {
+	ds, err := repo.dao.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := repo.dao.FindAllAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := repo.dao.CountCourses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliasMap := make(map[int64][]string, len(ds))
+	for _, a := range aliases {
+		aliasMap[a.DepartmentId] = append(aliasMap[a.DepartmentId], a.Alias)
+	}
+	countMap := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		countMap[c.DepartmentId] = c.Cnt
+	}
+	res := make([]domain.DepartmentSummary, 0, len(ds))
+	for _, d := range ds {
+		dd := repo.toDomain(d)
+		dd.Aliases = aliasMap[d.Id]
+		res = append(res, domain.DepartmentSummary{
+			Department:  dd,
+			CourseCount: countMap[d.Id],
+		})
+	}
+	return res, nil
+}
+
+func (repo *CachedDepartmentRepository) Save(ctx context.Context, d domain.Department) (int64, error) {
+	id, cids, err := repo.dao.Save(ctx, repo.toEntity(d), d.Aliases)
+	if err != nil {
+		return 0, err
+	}
+	repo.delCourseCache(cids)
+	return id, nil
+}
+
+func (repo *CachedDepartmentRepository) Merge(ctx context.Context, fromId int64, toId int64) error {
+	cids, err := repo.dao.Merge(ctx, fromId, toId)
+	if err != nil {
+		return err
+	}
+	repo.delCourseCache(cids)
+	return nil
+}
+
+// delCourseCache 课程缓存里有学院名，改名或合并的课程可能很多，异步删
+func (repo *CachedDepartmentRepository) delCourseCache(cids []int64) {
+	if len(cids) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		for _, cid := range cids {
+			er := repo.courseCache.Del(ctx, cid)
+			if er != nil {
+				repo.l.Error("删除课程缓存失败", logger.Error(er), logger.Int64("courseId", cid))
+			}
+		}
+	}()
+}
+
+func (repo *CachedDepartmentRepository) toEntity(d domain.Department) dao.Department {
+	return dao.Department{
+		Id:   d.Id,
+		Code: sql.NullString{String: d.Code, Valid: d.Code != ""},
+		Name: d.Name,
+	}
+}
+
+func (repo *CachedDepartmentRepository) toDomain(d dao.Department) domain.Department {
+	return domain.Department{
+		Id:   d.Id,
+		Code: d.Code.String,
+		Name: d.Name,
+	}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+)
+
+// HotCourseRepository 排行只存在 redis 里，丢了可以由离线任务按修读人数重建
+type HotCourseRepository interface {
+	BatchIncr(ctx context.Context, semester domain.Semester, courses []domain.Course) error
+	Replace(ctx context.Context, semester domain.Semester, hots []domain.HotCourse) error
+	// Top 返回的课程只有 Id
+	Top(ctx context.Context, semester domain.Semester, filter domain.HotCourseFilter, limit int64) ([]domain.HotCourse, error)
+}
+
+type CachedHotCourseRepository struct {
+	cache cache.HotCourseCache
+}
+
+func NewCachedHotCourseRepository(cache cache.HotCourseCache) HotCourseRepository {
+	return &CachedHotCourseRepository{cache: cache}
+}
+
+func (repo *CachedHotCourseRepository) BatchIncr(ctx context.Context, semester domain.Semester, courses []domain.Course) error {
+	return repo.cache.BatchIncr(ctx, semester, courses)
+}
+
+func (repo *CachedHotCourseRepository) Replace(ctx context.Context, semester domain.Semester, hots []domain.HotCourse) error {
+	return repo.cache.Replace(ctx, semester, hots)
+}
+
+func (repo *CachedHotCourseRepository) Top(ctx context.Context, semester domain.Semester, filter domain.HotCourseFilter,
+	limit int64) ([]domain.HotCourse, error) {
+	return repo.cache.Top(ctx, semester, filter, limit)
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+)
+
+type PlannedCourseRepository interface {
+	Create(ctx context.Context, uid int64, semester domain.Semester, courseId int64) error
+	Delete(ctx context.Context, uid int64, semester domain.Semester, courseId int64) error
+	// FindCourseIds 按加入计划的先后顺序
+	FindCourseIds(ctx context.Context, uid int64, semester domain.Semester) ([]int64, error)
+	Count(ctx context.Context, uid int64, semester domain.Semester) (int64, error)
+}
+
+// plannedCourseRepository 只在选课期间用，一个人的计划就几条，不缓存
+type plannedCourseRepository struct {
+	dao dao.PlannedCourseDAO
+}
+
+func NewPlannedCourseRepository(dao dao.PlannedCourseDAO) PlannedCourseRepository {
+	return &plannedCourseRepository{dao: dao}
+}
+
+func (repo *plannedCourseRepository) Create(ctx context.Context, uid int64, semester domain.Semester, courseId int64) error {
+	return repo.dao.Insert(ctx, dao.PlannedCourse{
+		Uid:      uid,
+		Year:     semester.YearStr(),
+		Term:     semester.TermStr(),
+		CourseId: courseId,
+	})
+}
+
+func (repo *plannedCourseRepository) Delete(ctx context.Context, uid int64, semester domain.Semester, courseId int64) error {
+	return repo.dao.Delete(ctx, uid, semester.YearStr(), semester.TermStr(), courseId)
+}
+
+func (repo *plannedCourseRepository) FindCourseIds(ctx context.Context, uid int64, semester domain.Semester) ([]int64, error) {
+	pcs, err := repo.dao.FindByUidYearTerm(ctx, uid, semester.YearStr(), semester.TermStr())
+	if err != nil {
+		return nil, err
+	}
+	cids := make([]int64, 0, len(pcs))
+	for _, pc := range pcs {
+		cids = append(cids, pc.CourseId)
+	}
+	return cids, nil
+}
+
+func (repo *plannedCourseRepository) Count(ctx context.Context, uid int64, semester domain.Semester) (int64, error) {
+	return repo.dao.CountByUidYearTerm(ctx, uid, semester.YearStr(), semester.TermStr())
+}
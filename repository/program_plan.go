@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/ecodeclub/ekit/slice"
+	"strconv"
+)
+
+var ErrProgramPlanNotFound = dao.ErrRecordNorFound
+
+type ProgramPlanRepository interface {
+	Save(ctx context.Context, plan domain.ProgramPlan) error
+	FindByMajorAndEntryYear(ctx context.Context, major string, entryYear int) (domain.ProgramPlan, error)
+}
+
+// programPlanRepository 培养方案一年才导入一次，查询也不频繁，不缓存
+type programPlanRepository struct {
+	dao dao.ProgramPlanDAO
+}
+
+func NewProgramPlanRepository(dao dao.ProgramPlanDAO) ProgramPlanRepository {
+	return &programPlanRepository{dao: dao}
+}
+
+func (repo *programPlanRepository) Save(ctx context.Context, plan domain.ProgramPlan) error {
+	return repo.dao.Save(ctx,
+		dao.ProgramPlan{
+			Major:        plan.Major,
+			EntryYear:    strconv.Itoa(plan.EntryYear),
+			TotalCredits: plan.TotalCredits,
+		},
+		slice.Map(plan.Categories, func(idx int, src domain.CategoryRequirement) dao.PlanCategory {
+			return dao.PlanCategory{
+				Property:   int32(src.Property),
+				MinCredits: src.MinCredits,
+			}
+		}),
+		slice.Map(plan.RequiredCourses, func(idx int, src domain.RequiredCourse) dao.PlanRequiredCourse {
+			return dao.PlanRequiredCourse{
+				CourseCode: src.CourseCode,
+				Name:       src.Name,
+			}
+		}))
+}
+
+func (repo *programPlanRepository) FindByMajorAndEntryYear(ctx context.Context, major string,
+	entryYear int) (domain.ProgramPlan, error) {
+	p, err := repo.dao.FindByMajorAndEntryYear(ctx, major, strconv.Itoa(entryYear))
+	if err != nil {
+		return domain.ProgramPlan{}, err
+	}
+	categories, err := repo.dao.FindCategories(ctx, p.Id)
+	if err != nil {
+		return domain.ProgramPlan{}, err
+	}
+	courses, err := repo.dao.FindRequiredCourses(ctx, p.Id)
+	if err != nil {
+		return domain.ProgramPlan{}, err
+	}
+	return domain.ProgramPlan{
+		Id:           p.Id,
+		Major:        p.Major,
+		EntryYear:    entryYear,
+		TotalCredits: p.TotalCredits,
+		Categories: slice.Map(categories, func(idx int, src dao.PlanCategory) domain.CategoryRequirement {
+			return domain.CategoryRequirement{
+				Property:   coursev1.CourseProperty(src.Property),
+				MinCredits: src.MinCredits,
+			}
+		}),
+		RequiredCourses: slice.Map(courses, func(idx int, src dao.PlanRequiredCourse) domain.RequiredCourse {
+			return domain.RequiredCourse{
+				CourseCode: src.CourseCode,
+				Name:       src.Name,
+			}
+		}),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository/cache"
+	"time"
+)
+
+// RelatedCourseRepository 相关课程由离线任务算好之后只存在 redis 里，过期了就等下一次任务
+type RelatedCourseRepository interface {
+	// FindByCourseId 返回的课程只有 Id
+	FindByCourseId(ctx context.Context, cid int64, limit int64) ([]domain.RelatedCourse, error)
+	Save(ctx context.Context, cid int64, related []domain.RelatedCourse, expiration time.Duration) error
+}
+
+type CachedRelatedCourseRepository struct {
+	cache cache.RelatedCourseCache
+}
+
+func NewCachedRelatedCourseRepository(cache cache.RelatedCourseCache) RelatedCourseRepository {
+	return &CachedRelatedCourseRepository{cache: cache}
+}
+
+func (repo *CachedRelatedCourseRepository) FindByCourseId(ctx context.Context, cid int64,
+	limit int64) ([]domain.RelatedCourse, error) {
+	return repo.cache.Get(ctx, cid, limit)
+}
+
+func (repo *CachedRelatedCourseRepository) Save(ctx context.Context, cid int64, related []domain.RelatedCourse,
+	expiration time.Duration) error {
+	return repo.cache.Set(ctx, cid, related, expiration)
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository/dao"
+)
+
+// TeacherRepository 老师是在写课程的时候顺带拆出来的，这里只有查询
+type TeacherRepository interface {
+	FindById(ctx context.Context, id int64) (domain.Teacher, error)
+	FindByName(ctx context.Context, name string) (domain.Teacher, error)
+	FindCourseIdsByTeacherId(ctx context.Context, tid int64) ([]int64, error)
+}
+
+type teacherRepository struct {
+	dao dao.TeacherDAO
+}
+
+func NewTeacherRepository(dao dao.TeacherDAO) TeacherRepository {
+	return &teacherRepository{dao: dao}
+}
+
+func (repo *teacherRepository) FindById(ctx context.Context, id int64) (domain.Teacher, error) {
+	t, err := repo.dao.FindById(ctx, id)
+	return repo.toDomain(t), err
+}
+
+func (repo *teacherRepository) FindByName(ctx context.Context, name string) (domain.Teacher, error) {
+	t, err := repo.dao.FindByName(ctx, name)
+	return repo.toDomain(t), err
+}
+
+func (repo *teacherRepository) FindCourseIdsByTeacherId(ctx context.Context, tid int64) ([]int64, error) {
+	return repo.dao.FindCourseIdsByTeacherId(ctx, tid)
+}
+
+func (repo *teacherRepository) toDomain(t dao.Teacher) domain.Teacher {
+	return domain.Teacher{
+		Id:   t.Id,
+		Name: t.Name,
+	}
+}
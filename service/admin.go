@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotAdmin = errors.New("不是管理员")
+
+// AdminService 修改校历、学院这些全局数据的接口调用前要检查操作人，网关只负责确认 uid 是谁
+type AdminService interface {
+	CheckAdmin(ctx context.Context, uid int64) error
+}
+
+type adminService struct {
+	uids map[int64]struct{}
+}
+
+// NewAdminService 管理员名单来自配置，改名单要重启
+func NewAdminService(uids []int64) AdminService {
+	m := make(map[int64]struct{}, len(uids))
+	for _, uid := range uids {
+		m[uid] = struct{}{}
+	}
+	return &adminService{uids: m}
+}
+
+func (s *adminService) CheckAdmin(ctx context.Context, uid int64) error {
+	if _, ok := s.uids[uid]; !ok {
+		return ErrNotAdmin
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository"
+	"time"
+)
+
+var (
+	ErrInvalidAcademicTerm  = errors.New("校历不合法")
+	ErrAcademicTermNotFound = errors.New("校历中没有这个学年期")
+)
+
+// CalendarService 校历，回答“现在是哪个学年期，是否在选课”
+type CalendarService interface {
+	// Current 当前学年期，校历里没有覆盖到现在的学年期或者查询失败时，使用配置文件中的兜底值
+	Current(ctx context.Context) domain.CurrentTerm
+	UpsertTerm(ctx context.Context, term domain.AcademicTerm) error
+	ListTerms(ctx context.Context) ([]domain.AcademicTerm, error)
+	GetTerm(ctx context.Context, semester domain.Semester) (domain.AcademicTerm, error)
+}
+
+type calendarService struct {
+	repo     repository.CalendarRepository
+	fallback domain.CurrentTerm
+	l        logger.Logger
+}
+
+func NewCalendarService(repo repository.CalendarRepository, fallback domain.CurrentTerm, l logger.Logger) CalendarService {
+	return &calendarService{repo: repo, fallback: fallback, l: l}
+}
+
+func (s *calendarService) Current(ctx context.Context) domain.CurrentTerm {
+	terms, err := s.repo.FindAll(ctx)
+	if err != nil {
+		s.l.Error("查询校历失败，使用兜底的学年期", logger.Error(err))
+		return s.fallback
+	}
+	now := time.Now()
+	// 升序排列的，倒着找第一个已经开始的学年期，选课开始就算进入下一个学年期了
+	for i := len(terms) - 1; i >= 0; i-- {
+		if !terms[i].OpenTime().After(now) {
+			return domain.CurrentTerm{
+				Semester:  terms[i].Semester,
+				Selecting: terms[i].Selecting(now),
+			}
+		}
+	}
+	return s.fallback
+}
+
+func (s *calendarService) UpsertTerm(ctx context.Context, term domain.AcademicTerm) error {
+	// 没传的时间戳转过来是 1970 年，结束时间要晚于开始时间，所以只查开始时间就够了
+	if term.Semester.IsZero() || term.StartTime.UnixMilli() <= 0 || term.SelectionStartTime.UnixMilli() <= 0 ||
+		!term.StartTime.Before(term.EndTime) ||
+		!term.SelectionStartTime.Before(term.SelectionEndTime) {
+		return ErrInvalidAcademicTerm
+	}
+	return s.repo.Upsert(ctx, term)
+}
+
+func (s *calendarService) ListTerms(ctx context.Context) ([]domain.AcademicTerm, error) {
+	return s.repo.FindAll(ctx)
+}
+
+func (s *calendarService) GetTerm(ctx context.Context, semester domain.Semester) (domain.AcademicTerm, error) {
+	terms, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return domain.AcademicTerm{}, err
+	}
+	for _, t := range terms {
+		if t.Semester == semester {
+			return t, nil
+		}
+	}
+	return domain.AcademicTerm{}, ErrAcademicTermNotFound
+}
@@ -2,37 +2,79 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	ccnuv1 "github.com/MuxiKeStack/be-api/gen/proto/ccnu/v1"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
 	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
 	"github.com/MuxiKeStack/be-course/pkg/stringsx"
 	"github.com/MuxiKeStack/be-course/repository"
 	"github.com/ecodeclub/ekit/slice"
 	"golang.org/x/sync/errgroup"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type CourseService interface {
-	// List 这里的 uid 作为变长参数作用是这个 uid 是可选的，只有装饰容错的时候才需要传入一个 uid
-	SubscriptionList(ctx context.Context, studentId string, password string, year string,
-		term string, uid ...int64) ([]domain.CourseSubscription, error)
-	GetDetailById(ctx context.Context, id int64) (domain.Course, error) //在这里面包括成绩
+	// List 这里的 uid 作为变长参数作用是这个 uid 是可选的，只有装饰容错的时候才需要传入一个 uid，
+	// 传入了 uid 时会顺带记录从成绩接口拿到的成绩，用于成绩统计
+	// semester 为零值表示查询全部学年期
+	SubscriptionList(ctx context.Context, studentId string, password string, semester domain.Semester,
+		uid ...int64) ([]domain.CourseSubscription, error)
+	// GetDetailById 成绩统计见 GradeService
+	GetDetailById(ctx context.Context, id int64) (domain.Course, error)
+	// GetDetailsByIds 按 ids 的顺序返回，不存在的课程会被跳过
+	GetDetailsByIds(ctx context.Context, ids []int64) ([]domain.Course, error)
 	FindIdOrCreateByCourse(ctx context.Context, course domain.Course) (int64, error)
 	FindIdOrUpsertByCourse(ctx context.Context, course domain.Course) (int64, error)
 	GetSubscriberUidsByCourseId(ctx context.Context, courseId int64, curUid int64, limit int64) ([]int64, error)
-	// FindSubscriptionsByUidYearTermAlive TTL 为-1表示永不过期
-	FindSubscriptionsByUidYearTermAlive(ctx context.Context, uid int64, year string, term string,
+	// FindSubscriptionsByUidSemesterAlive semester 为零值表示全部学年期，TTL 为-1表示永不过期
+	FindSubscriptionsByUidSemesterAlive(ctx context.Context, uid int64, semester domain.Semester,
 		TTL time.Duration) ([]domain.CourseSubscription, error)
 	Subscribed(ctx context.Context, uid int64, courseId int64) (bool, error)
+	// List 按学院、课程性质、学分范围、老师筛选课程，按 id 升序，curId 为 0 时表示第一页
+	List(ctx context.Context, filter domain.CourseFilter, curId int64, limit int64) ([]domain.Course, error)
+	// Suggest 输入联想，prefix 可以是汉字前缀、全拼前缀或拼音首字母，如 "大学"、"daxue"、"dxty"
+	Suggest(ctx context.Context, prefix string, limit int64) (domain.CourseSuggestion, error)
+	// Search 按课程名、老师、课程号、学院模糊搜索课程，curId 为 0 时表示第一页
+	// 相关度会随着课程表变化，翻页时可能漏掉或者重复几条，不保证严格不重不漏
+	Search(ctx context.Context, keyword string, curScore float64, curId int64, limit int64) ([]domain.CourseSearchHit, error)
+	// GetOfferings 同一门课（课程号和课程名都相同）所有老师的开课记录，最近的学年期在前
+	GetOfferings(ctx context.Context, courseId int64) ([]domain.CourseOffering, error)
+	// GetSiblings 课程号或课程名相同的其他课程，不包括自己，课程号和课程名都相同的在前，其次按修读人数降序
+	GetSiblings(ctx context.Context, courseId int64) ([]domain.CourseSibling, error)
+	GetSubscriberStats(ctx context.Context, courseId int64) (domain.SubscriberStats, error)
+	// GetCreditSummary 按课程性质和学年期汇总用户修读记录的学分，重修的课（课程号相同）只算最后一次
+	GetCreditSummary(ctx context.Context, uid int64) (domain.CreditSummary, error)
 }
 
+var (
+	ErrSearchKeywordTooShort = errors.New("搜索关键词过短")
+	ErrInvalidCreditRange    = errors.New("学分范围不合法")
+	ErrTooManyCourseIds      = errors.New("一次查询的课程数量过多")
+)
+
+const (
+	// 与 MySQL 的 ngram_token_size 保持一致，比这个短的词全文索引搜不出来
+	minSearchKeywordLen = 2
+	maxSearchLimit      = 50
+	maxSuggestLimit     = 10
+	maxListLimit        = 100
+	maxDetailsIds       = 100
+	// 通识课的课程名可能很常见，限制一下同名课程的数量
+	maxSiblings = 50
+)
+
 type courseService struct {
-	ccnu        ccnuv1.CCNUServiceClient
-	repo        repository.CourseRepository
-	subRepo     repository.CourseSubscriptionRepository
-	currentYear string
-	currentTerm string
+	ccnu         ccnuv1.CCNUServiceClient
+	repo         repository.CourseRepository
+	subRepo      repository.CourseSubscriptionRepository
+	calendar     CalendarService
+	offeringRepo repository.CourseOfferingRepository
+	l            logger.Logger
 }
 
 func (s *courseService) Subscribed(ctx context.Context, uid int64, courseId int64) (bool, error) {
@@ -40,15 +82,16 @@ func (s *courseService) Subscribed(ctx context.Context, uid int64, courseId int6
 }
 
 func NewCourseService(ccnu ccnuv1.CCNUServiceClient, repo repository.CourseRepository, subRepo repository.CourseSubscriptionRepository,
-	currentYear string, currentTerm string) CourseService {
-	return &courseService{ccnu: ccnu, repo: repo, subRepo: subRepo, currentYear: currentYear, currentTerm: currentTerm}
+	calendar CalendarService, offeringRepo repository.CourseOfferingRepository, l logger.Logger) CourseService {
+	return &courseService{ccnu: ccnu, repo: repo, subRepo: subRepo, calendar: calendar, offeringRepo: offeringRepo, l: l}
 }
 
 // SubscriptionList 查询所有时查询历史的所有，并不包括当前的
-func (s *courseService) SubscriptionList(ctx context.Context, studentId string, password string, year string,
-	term string, uid ...int64) ([]domain.CourseSubscription, error) {
-	// 从课程接口，判断是否选课中，好像都无所谓，都返回就行了，但是后面发表课评的时候要判断是否选课中
-	isHistory := year < s.currentYear || year == s.currentYear && term < s.currentTerm || year == "" || term == ""
+func (s *courseService) SubscriptionList(ctx context.Context, studentId string, password string, semester domain.Semester,
+	uid ...int64) ([]domain.CourseSubscription, error) {
+	// 从课程接口，判断是否选课中，好像都无所谓，都返回就行了，发表课评时是否选课中由 EvaluableService 判断
+	cur := s.calendar.Current(ctx)
+	isHistory := semester.IsHistory(cur.Semester)
 	var src ccnuv1.Source
 	// 判断学年期，从成绩接口还是老接口
 	if isHistory {
@@ -60,13 +103,15 @@ func (s *courseService) SubscriptionList(ctx context.Context, studentId string,
 	res, err := s.ccnu.CourseList(ctx, &ccnuv1.CourseListRequest{
 		StudentId: studentId,
 		Password:  password,
-		Year:      year,
-		Term:      term,
+		Year:      semester.YearStr(),
+		Term:      semester.TermStr(),
 		Source:    src,
 	})
 	if err != nil {
 		return nil, err
 	}
+	var parseErr error
+	fetchedAt := time.Now()
 	courseSubscriptions := slice.Map(res.Courses, func(idx int, src *ccnuv1.Course) domain.CourseSubscription {
 		// 体育课比较特别，要特殊处理
 		isSport := strings.HasPrefix(src.GetName(), "大学体育")
@@ -89,7 +134,7 @@ func (s *courseService) SubscriptionList(ctx context.Context, studentId string,
 				src.Name = fmt.Sprintf("%s：%s", src.GetName(), className)
 			}
 		}
-		return domain.CourseSubscription{
+		cs := domain.CourseSubscription{
 			Course: domain.Course{
 				CourseCode: src.GetCourseCode(),
 				Name:       src.GetName(),
@@ -99,10 +144,42 @@ func (s *courseService) SubscriptionList(ctx context.Context, studentId string,
 				Credit:     src.GetCredit(),
 			},
 			//Uid: uid[0],    // 这个不一定需要因为调用方一定知道自己的uid
-			Year: src.Year,
-			Term: src.Term,
 		}
+		cs.Sessions = slice.FilterMap(src.GetSessions(), func(idx int, src *ccnuv1.Session) (domain.MeetingSession, bool) {
+			ms := domain.MeetingSession{
+				Weekday:     int(src.GetWeekday()),
+				StartPeriod: int(src.GetStartPeriod()),
+				EndPeriod:   int(src.GetEndPeriod()),
+				Weeks: slice.Map(src.GetWeeks(), func(idx int, src int32) int {
+					return int(src)
+				}),
+				Classroom: strings.TrimSpace(src.GetClassroom()),
+			}
+			// 教务系统里偶尔有没排课的，丢掉就行，不影响课程本身
+			return ms, ms.Valid()
+		})
+		if g := src.GetGrade(); g != nil {
+			cs.Grade = &domain.SubscriptionGrade{
+				Grade: domain.Grade{
+					Regular: g.GetRegular(),
+					Final:   g.GetFinal(),
+					Total:   g.GetTotal(),
+				},
+				Source:    coursev1.GradeSource_GradeSourceGradeApi,
+				FetchedAt: fetchedAt,
+			}
+		}
+		var er error
+		cs.Semester, er = domain.ParseSemester(src.GetYear(), src.GetTerm())
+		if er != nil && parseErr == nil {
+			parseErr = er
+		}
+		return cs
 	})
+	if parseErr != nil {
+		// 教务系统返回的学年期格式不对，大概率是接口变了，不能带着错误的数据往下走
+		return nil, fmt.Errorf("教务系统返回的学年期不合法: %w", parseErr)
+	}
 
 	// 要在这里聚合出courseId，两种查询结果要采用不同的聚合手段,两个不同的聚合id的接口	[优胜劣汰]
 	var eg errgroup.Group
@@ -129,13 +206,61 @@ func (s *courseService) SubscriptionList(ctx context.Context, studentId string,
 	if err != nil {
 		return nil, err
 	}
+	go s.recordOfferings(courseSubscriptions)
+	if len(uid) > 0 {
+		go s.recordSessions(uid[0], courseSubscriptions)
+	}
 	return courseSubscriptions, err
 }
 
+// recordOfferings 不管是不是选上的课，教务系统返回了就说明这学期开了
+// 但还在选课的学年期不算，选课结束之前课程还可能被取消
+func (s *courseService) recordOfferings(css []domain.CourseSubscription) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cur := s.calendar.Current(ctx)
+	offerings := slice.FilterMap(css, func(idx int, src domain.CourseSubscription) (domain.CourseOffering, bool) {
+		return domain.CourseOffering{
+			Course:   domain.Course{Id: src.Course.Id},
+			Semester: src.Semester,
+		}, !src.Semester.IsZero() && !(cur.Selecting && src.Semester == cur.Semester)
+	})
+	if len(offerings) == 0 {
+		return
+	}
+	err := s.offeringRepo.BatchCreate(ctx, offerings)
+	if err != nil {
+		s.l.Error("记录开课学年期失败", logger.Error(err))
+	}
+}
+
+// recordSessions 上课安排是用户自己的教学班的，选课期间也要记，课表要用
+func (s *courseService) recordSessions(uid int64, css []domain.CourseSubscription) {
+	css = slice.FilterMap(css, func(idx int, src domain.CourseSubscription) (domain.CourseSubscription, bool) {
+		return src, !src.Semester.IsZero() && len(src.Sessions) > 0
+	})
+	if len(css) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := s.offeringRepo.SaveSessions(ctx, uid, css)
+	if err != nil {
+		s.l.Error("记录上课安排失败", logger.Error(err), logger.Int64("uid", uid))
+	}
+}
+
 func (s *courseService) GetDetailById(ctx context.Context, id int64) (domain.Course, error) {
 	return s.repo.FindById(ctx, id)
 }
 
+func (s *courseService) GetDetailsByIds(ctx context.Context, ids []int64) ([]domain.Course, error) {
+	if len(ids) > maxDetailsIds {
+		return nil, ErrTooManyCourseIds
+	}
+	return s.repo.FindByIds(ctx, ids)
+}
+
 func (s *courseService) FindIdOrUpsertByCourse(ctx context.Context, course domain.Course) (int64, error) {
 	// 这个语义逻辑保持在DAO的单个事务里面性能在初期其实更好，但是代码结构没那么好看，
 	// 而且这里只是upsert是有限次数的，只会执行ccnu的总课程数次，很少了，后期数据全了，
@@ -177,25 +302,220 @@ func (s *courseService) FindIdOrCreateByCourse(ctx context.Context, course domai
 	return s.repo.FindIdByCourse(ctx, course)
 }
 
-// FindByUidYearTermAlive year , term , TTL 可以为空，代表全部
-func (s *courseService) FindSubscriptionsByUidYearTermAlive(ctx context.Context, uid int64, year string, term string,
+// FindSubscriptionsByUidSemesterAlive semester 可以为零值，代表全部
+func (s *courseService) FindSubscriptionsByUidSemesterAlive(ctx context.Context, uid int64, semester domain.Semester,
 	TTL time.Duration) ([]domain.CourseSubscription, error) {
 	// 不使用join语句，分步：先拿到courseIds
-	courseSubs, err := s.subRepo.FindByUidYearTermAlive(ctx, uid, year, term, TTL)
+	courseSubs, err := s.subRepo.FindByUidSemesterAlive(ctx, uid, semester, TTL)
 	if err != nil {
 		return nil, err
 	}
-	// 填充具体的course信息
-	for i := range courseSubs {
-		var er error
-		courseSubs[i].Course, er = s.repo.FindById(ctx, courseSubs[i].Course.Id)
-		if er != nil {
-			return nil, err
+	// 填充具体的course信息，批量查，缓存没命中的一次查数据库
+	cids := slice.Map(courseSubs, func(idx int, src domain.CourseSubscription) int64 {
+		return src.Course.Id
+	})
+	courses, err := s.repo.FindByIds(ctx, cids)
+	if err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]domain.Course, len(courses))
+	for _, c := range courses {
+		courseMap[c.Id] = c
+	}
+	res := make([]domain.CourseSubscription, 0, len(courseSubs))
+	var missing []int64
+	for _, cs := range courseSubs {
+		c, ok := courseMap[cs.Course.Id]
+		if !ok {
+			// 课程被删了，订阅也没有意义了，但大概率是导入课程出了问题，要留个记录
+			missing = append(missing, cs.Course.Id)
+			continue
 		}
+		cs.Course = c
+		res = append(res, cs)
+	}
+	if len(missing) > 0 {
+		s.l.Error("修读记录对应的课程不存在", logger.Int64("uid", uid), logger.Any("courseIds", missing))
+	}
+	return res, nil
+}
+
+func (s *courseService) List(ctx context.Context, filter domain.CourseFilter, curId int64,
+	limit int64) ([]domain.Course, error) {
+	if filter.MinCredit < 0 || filter.MaxCredit < 0 ||
+		filter.MaxCredit > 0 && filter.MinCredit > filter.MaxCredit {
+		return nil, ErrInvalidCreditRange
+	}
+	if limit <= 0 || limit > maxListLimit {
+		limit = maxListLimit
 	}
-	return courseSubs, nil
+	return s.repo.List(ctx, filter, curId, limit)
+}
+
+func (s *courseService) Suggest(ctx context.Context, prefix string, limit int64) (domain.CourseSuggestion, error) {
+	// 拼音统一按小写存储，这里也转成小写，空格对拼音没有意义
+	prefix = strings.ToLower(strings.ReplaceAll(prefix, " ", ""))
+	if prefix == "" {
+		return domain.CourseSuggestion{}, nil
+	}
+	if limit <= 0 || limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+	return s.repo.Suggest(ctx, prefix, limit)
+}
+
+func (s *courseService) Search(ctx context.Context, keyword string, curScore float64, curId int64,
+	limit int64) ([]domain.CourseSearchHit, error) {
+	keyword = strings.TrimSpace(keyword)
+	if utf8.RuneCountInString(keyword) < minSearchKeywordLen {
+		return nil, ErrSearchKeywordTooShort
+	}
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	return s.repo.Search(ctx, keyword, curScore, curId, limit)
 }
 
 func (s *courseService) GetSubscriberUidsByCourseId(ctx context.Context, courseId int64, curUid int64, limit int64) ([]int64, error) {
 	return s.subRepo.FindSubscriberUidsByCourseId(ctx, courseId, curUid, limit)
 }
+
+func (s *courseService) GetOfferings(ctx context.Context, courseId int64) ([]domain.CourseOffering, error) {
+	c, err := s.repo.FindById(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	siblings, err := s.repo.FindByCodeAndName(ctx, c.CourseCode, c.Name)
+	if err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]domain.Course, len(siblings))
+	for _, sb := range siblings {
+		courseMap[sb.Id] = sb
+	}
+	offerings, err := s.offeringRepo.FindByCourseIds(ctx, slice.Map(siblings, func(idx int, src domain.Course) int64 {
+		return src.Id
+	}))
+	if err != nil {
+		return nil, err
+	}
+	for i := range offerings {
+		offerings[i].Course = courseMap[offerings[i].Course.Id]
+	}
+	// 同一学年期按老师排，看得出来这学期有哪几个老师开
+	sort.Slice(offerings, func(i, j int) bool {
+		if offerings[i].Semester != offerings[j].Semester {
+			return offerings[i].Semester.After(offerings[j].Semester)
+		}
+		return offerings[i].Course.Teacher < offerings[j].Course.Teacher
+	})
+	return offerings, nil
+}
+
+func (s *courseService) GetSiblings(ctx context.Context, courseId int64) ([]domain.CourseSibling, error) {
+	c, err := s.repo.FindById(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	courses, err := s.repo.FindSiblings(ctx, c.CourseCode, c.Name, maxSiblings+1)
+	if err != nil {
+		return nil, err
+	}
+	siblings := slice.FilterMap(courses, func(idx int, src domain.Course) (domain.CourseSibling, bool) {
+		return domain.CourseSibling{
+			Course:   src,
+			SameCode: src.CourseCode == c.CourseCode,
+			SameName: src.Name == c.Name,
+		}, src.Id != courseId
+	})
+	// 课程号和课程名都相同的在前面，截断时优先保留
+	if len(siblings) > maxSiblings {
+		siblings = siblings[:maxSiblings]
+	}
+	counts, err := s.subRepo.CountSubscribers(ctx, slice.Map(siblings, func(idx int, src domain.CourseSibling) int64 {
+		return src.Course.Id
+	}))
+	if err != nil {
+		return nil, err
+	}
+	for i := range siblings {
+		siblings[i].SubscriberCount = counts[siblings[i].Course.Id]
+	}
+	sort.SliceStable(siblings, func(i, j int) bool {
+		bi := siblings[i].SameCode && siblings[i].SameName
+		bj := siblings[j].SameCode && siblings[j].SameName
+		if bi != bj {
+			return bi
+		}
+		return siblings[i].SubscriberCount > siblings[j].SubscriberCount
+	})
+	return siblings, nil
+}
+
+func (s *courseService) GetSubscriberStats(ctx context.Context, courseId int64) (domain.SubscriberStats, error) {
+	return s.subRepo.FindSubscriberStats(ctx, courseId)
+}
+
+func (s *courseService) GetCreditSummary(ctx context.Context, uid int64) (domain.CreditSummary, error) {
+	css, err := s.FindSubscriptionsByUidSemesterAlive(ctx, uid, domain.Semester{}, -1)
+	if err != nil {
+		return domain.CreditSummary{}, err
+	}
+	cur := s.calendar.Current(ctx).Semester
+	var (
+		summary    domain.CreditSummary
+		byProperty = make(map[coursev1.CourseProperty]*domain.CreditTally)
+		bySemester = make(map[domain.Semester]*domain.CreditTally)
+	)
+	for _, cs := range latestAttempts(css) {
+		counted, inProgress := creditState(cs, cur)
+		if !counted {
+			continue
+		}
+		credit := cs.Course.Credit
+		summary.Total.Add(credit, inProgress)
+		if byProperty[cs.Course.Property] == nil {
+			byProperty[cs.Course.Property] = &domain.CreditTally{}
+		}
+		byProperty[cs.Course.Property].Add(credit, inProgress)
+		if bySemester[cs.Semester] == nil {
+			bySemester[cs.Semester] = &domain.CreditTally{}
+		}
+		bySemester[cs.Semester].Add(credit, inProgress)
+	}
+	for p, t := range byProperty {
+		summary.ByProperty = append(summary.ByProperty, domain.PropertyCredits{Property: p, Tally: *t})
+	}
+	sort.Slice(summary.ByProperty, func(i, j int) bool {
+		return summary.ByProperty[i].Property < summary.ByProperty[j].Property
+	})
+	for sm, t := range bySemester {
+		summary.BySemester = append(summary.BySemester, domain.SemesterCredits{Semester: sm, Tally: *t})
+	}
+	sort.Slice(summary.BySemester, func(i, j int) bool {
+		return summary.BySemester[i].Semester.Before(summary.BySemester[j].Semester)
+	})
+	return summary, nil
+}
+
+// latestAttempts 同一门课修了好几次（挂了重修），只保留最后一次
+// 重修经常换老师，换了老师就是另一条课程记录，所以按课程号而不是课程 id 去重
+func latestAttempts(css []domain.CourseSubscription) map[string]domain.CourseSubscription {
+	latest := make(map[string]domain.CourseSubscription, len(css))
+	for _, cs := range css {
+		old, ok := latest[cs.Course.CourseCode]
+		if !ok || cs.Semester.After(old.Semester) {
+			latest[cs.Course.CourseCode] = cs
+		}
+	}
+	return latest
+}
+
+// creditState 当前及以后学年期的课算在修，之前的课挂了不计学分，没有成绩的当作通过
+func creditState(cs domain.CourseSubscription, cur domain.Semester) (counted bool, inProgress bool) {
+	inProgress = !cs.Semester.Before(cur)
+	if !inProgress && cs.Grade != nil && cs.Grade.Grade.Total < domain.PassScore {
+		return false, false
+	}
+	return true, inProgress
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/ecodeclub/ekit/slice"
+)
+
+const maxFavoriteListLimit = 50
+
+type CourseFavoriteService interface {
+	// Add 重复收藏不会报错
+	Add(ctx context.Context, uid int64, courseId int64) error
+	Remove(ctx context.Context, uid int64, courseId int64) error
+	// List 最近收藏的在前，curId 是上一页最后一条收藏的 Id，课程被删了的收藏会被跳过
+	List(ctx context.Context, uid int64, curId int64, limit int64) ([]domain.CourseFavorite, error)
+	Count(ctx context.Context, courseId int64) (int64, error)
+}
+
+type courseFavoriteService struct {
+	repo       repository.CourseFavoriteRepository
+	courseRepo repository.CourseRepository
+}
+
+func NewCourseFavoriteService(repo repository.CourseFavoriteRepository,
+	courseRepo repository.CourseRepository) CourseFavoriteService {
+	return &courseFavoriteService{repo: repo, courseRepo: courseRepo}
+}
+
+func (s *courseFavoriteService) Add(ctx context.Context, uid int64, courseId int64) error {
+	if _, err := s.courseRepo.FindById(ctx, courseId); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, uid, courseId)
+}
+
+func (s *courseFavoriteService) Remove(ctx context.Context, uid int64, courseId int64) error {
+	return s.repo.Delete(ctx, uid, courseId)
+}
+
+func (s *courseFavoriteService) List(ctx context.Context, uid int64, curId int64,
+	limit int64) ([]domain.CourseFavorite, error) {
+	if limit <= 0 || limit > maxFavoriteListLimit {
+		limit = maxFavoriteListLimit
+	}
+	fs, err := s.repo.FindByUid(ctx, uid, curId, limit)
+	if err != nil || len(fs) == 0 {
+		return fs, err
+	}
+	courses, err := s.courseRepo.FindByIds(ctx, slice.Map(fs, func(idx int, src domain.CourseFavorite) int64 {
+		return src.Course.Id
+	}))
+	if err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]domain.Course, len(courses))
+	for _, c := range courses {
+		courseMap[c.Id] = c
+	}
+	return slice.FilterMap(fs, func(idx int, src domain.CourseFavorite) (domain.CourseFavorite, bool) {
+		c, ok := courseMap[src.Course.Id]
+		src.Course = c
+		return src, ok
+	}), nil
+}
+
+func (s *courseFavoriteService) Count(ctx context.Context, courseId int64) (int64, error) {
+	return s.repo.Count(ctx, courseId)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/ecodeclub/ekit/slice"
+	"sort"
+)
+
+var (
+	ErrNotSelecting   = errors.New("不在选课期间")
+	ErrTooManyPlanned = errors.New("计划的课程数量过多")
+)
+
+// 一学期正常也就十几门课
+const maxPlannedCourses = 50
+
+type CoursePlanService interface {
+	// Add 只能在选课期间往当前选课的学年期里加
+	Add(ctx context.Context, uid int64, courseId int64) error
+	Remove(ctx context.Context, uid int64, courseId int64) error
+	// Get 选课结束之后会按修读记录（也就是查课程列表时教务系统返回的结果）对账，
+	// 用户还没有查过这学期的课程列表时，计划里的课都会是没选上
+	Get(ctx context.Context, uid int64, semester domain.Semester) (domain.CoursePlan, error)
+}
+
+type coursePlanService struct {
+	repo         repository.PlannedCourseRepository
+	courseRepo   repository.CourseRepository
+	offeringRepo repository.CourseOfferingRepository
+	courseSvc    CourseService
+	calendar     CalendarService
+}
+
+func NewCoursePlanService(repo repository.PlannedCourseRepository, courseRepo repository.CourseRepository,
+	offeringRepo repository.CourseOfferingRepository, courseSvc CourseService, calendar CalendarService) CoursePlanService {
+	return &coursePlanService{repo: repo, courseRepo: courseRepo, offeringRepo: offeringRepo, courseSvc: courseSvc,
+		calendar: calendar}
+}
+
+func (s *coursePlanService) Add(ctx context.Context, uid int64, courseId int64) error {
+	cur := s.calendar.Current(ctx)
+	if !cur.Selecting {
+		return ErrNotSelecting
+	}
+	// 课程要存在
+	if _, err := s.courseRepo.FindById(ctx, courseId); err != nil {
+		return err
+	}
+	cnt, err := s.repo.Count(ctx, uid, cur.Semester)
+	if err != nil {
+		return err
+	}
+	if cnt >= maxPlannedCourses {
+		return ErrTooManyPlanned
+	}
+	return s.repo.Create(ctx, uid, cur.Semester, courseId)
+}
+
+func (s *coursePlanService) Remove(ctx context.Context, uid int64, courseId int64) error {
+	cur := s.calendar.Current(ctx)
+	if !cur.Selecting {
+		return ErrNotSelecting
+	}
+	return s.repo.Delete(ctx, uid, cur.Semester, courseId)
+}
+
+func (s *coursePlanService) Get(ctx context.Context, uid int64, semester domain.Semester) (domain.CoursePlan, error) {
+	if semester.IsZero() {
+		return domain.CoursePlan{}, domain.ErrInvalidSemester
+	}
+	cids, err := s.repo.FindCourseIds(ctx, uid, semester)
+	if err != nil {
+		return domain.CoursePlan{}, err
+	}
+	// FindByIds 按 cids 的顺序返回，也就是加入计划的先后顺序
+	courses, err := s.courseRepo.FindByIds(ctx, cids)
+	if err != nil {
+		return domain.CoursePlan{}, err
+	}
+	plan := domain.CoursePlan{Semester: semester}
+	byProperty := make(map[coursev1.CourseProperty]float64)
+	for _, c := range courses {
+		plan.Courses = append(plan.Courses, domain.PlannedCourse{
+			Course: c,
+			Status: coursev1.PlanStatus_PlanStatusPlanned,
+		})
+		plan.TotalCredits += c.Credit
+		byProperty[c.Property] += c.Credit
+	}
+	for p, credits := range byProperty {
+		plan.ByProperty = append(plan.ByProperty, domain.PlannedCredits{Property: p, Credits: credits})
+	}
+	sort.Slice(plan.ByProperty, func(i, j int) bool {
+		return plan.ByProperty[i].Property < plan.ByProperty[j].Property
+	})
+	plan.Conflicts, plan.Unknown, err = s.findConflicts(ctx, uid, courses, semester)
+	if err != nil {
+		return domain.CoursePlan{}, err
+	}
+	if s.selectionClosed(ctx, semester) {
+		err = s.reconcile(ctx, uid, &plan)
+	}
+	return plan, err
+}
+
+func (s *coursePlanService) selectionClosed(ctx context.Context, semester domain.Semester) bool {
+	cur := s.calendar.Current(ctx)
+	if cur.Semester == semester {
+		return !cur.Selecting
+	}
+	return semester.Before(cur.Semester)
+}
+
+// findConflicts 两两比较，计划里的课不多，courses 要按加入计划的先后排好，另外返回不知道上课时间的课
+func (s *coursePlanService) findConflicts(ctx context.Context, uid int64, courses []domain.Course,
+	semester domain.Semester) ([]domain.CourseConflict, []domain.Course, error) {
+	if len(courses) == 0 {
+		return nil, nil, nil
+	}
+	cids := make([]int64, 0, len(courses))
+	for _, c := range courses {
+		cids = append(cids, c.Id)
+	}
+	sessions, err := s.findSessions(ctx, uid, cids, semester)
+	if err != nil {
+		return nil, nil, err
+	}
+	unknown := slice.FilterMap(courses, func(idx int, src domain.Course) (domain.Course, bool) {
+		_, ok := sessions[src.Id]
+		return src, !ok
+	})
+	var conflicts []domain.CourseConflict
+	for i, a := range courses {
+		for _, b := range courses[i+1:] {
+			for _, sa := range sessions[a.Id] {
+				for _, sb := range sessions[b.Id] {
+					if sa.Overlaps(sb) {
+						conflicts = append(conflicts, domain.CourseConflict{
+							Course:       a,
+							Other:        b,
+							Session:      sa,
+							OtherSession: sb,
+						})
+					}
+				}
+			}
+		}
+	}
+	return conflicts, unknown, nil
+}
+
+// findSessions 已经选上的课用自己教学班的上课安排，还没选上的不知道会进哪个教学班，用人数最多的那个
+func (s *coursePlanService) findSessions(ctx context.Context, uid int64, cids []int64,
+	semester domain.Semester) (map[int64][]domain.MeetingSession, error) {
+	sessions, err := s.offeringRepo.FindSessions(ctx, uid, cids, semester)
+	if err != nil {
+		return nil, err
+	}
+	rest := slice.FilterMap(cids, func(idx int, src int64) (int64, bool) {
+		_, ok := sessions[src]
+		return src, !ok
+	})
+	if len(rest) == 0 {
+		return sessions, nil
+	}
+	common, err := s.offeringRepo.FindCommonSessions(ctx, rest, semester)
+	if err != nil {
+		return nil, err
+	}
+	for cid, ss := range common {
+		sessions[cid] = ss
+	}
+	return sessions, nil
+}
+
+func (s *coursePlanService) reconcile(ctx context.Context, uid int64, plan *domain.CoursePlan) error {
+	css, err := s.courseSvc.FindSubscriptionsByUidSemesterAlive(ctx, uid, plan.Semester, -1)
+	if err != nil {
+		return err
+	}
+	subscribed := make(map[int64]domain.Course, len(css))
+	for _, cs := range css {
+		subscribed[cs.Course.Id] = cs.Course
+	}
+	for i := range plan.Courses {
+		if _, ok := subscribed[plan.Courses[i].Course.Id]; ok {
+			plan.Courses[i].Status = coursev1.PlanStatus_PlanStatusSelected
+			delete(subscribed, plan.Courses[i].Course.Id)
+		} else {
+			plan.Courses[i].Status = coursev1.PlanStatus_PlanStatusNotSelected
+		}
+	}
+	for _, cs := range css {
+		if c, ok := subscribed[cs.Course.Id]; ok {
+			plan.Unplanned = append(plan.Unplanned, c)
+		}
+	}
+	plan.Reconciled = true
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"github.com/MuxiKeStack/be-course/domain"
+	"testing"
+)
+
+func TestLatestAttempts(t *testing.T) {
+	attempt := func(id int64, code string, year int, term int) domain.CourseSubscription {
+		return domain.CourseSubscription{
+			Course:   domain.Course{Id: id, CourseCode: code},
+			Semester: domain.Semester{Year: year, Term: term},
+		}
+	}
+	testCases := []struct {
+		name string
+		css  []domain.CourseSubscription
+		// 课程号对应留下来的那条修读记录的课程 id
+		want map[string]int64
+	}{
+		{
+			name: "没有重修",
+			css:  []domain.CourseSubscription{attempt(1, "A", 2022, 1), attempt(2, "B", 2022, 2)},
+			want: map[string]int64{"A": 1, "B": 2},
+		},
+		{
+			// 重修换了老师，课程 id 不一样，但课程号一样
+			name: "换老师重修只留最后一次",
+			css:  []domain.CourseSubscription{attempt(2, "A", 2023, 1), attempt(1, "A", 2022, 1)},
+			want: map[string]int64{"A": 2},
+		},
+		{
+			name: "同一学年的小学期在后",
+			css:  []domain.CourseSubscription{attempt(3, "A", 2022, 3), attempt(1, "A", 2022, 2)},
+			want: map[string]int64{"A": 3},
+		},
+		{
+			name: "没有修读记录",
+			want: map[string]int64{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := latestAttempts(tc.css)
+			if len(got) != len(tc.want) {
+				t.Fatalf("留下了 %d 门课, 期望 %d: %v", len(got), len(tc.want), got)
+			}
+			for code, id := range tc.want {
+				if got[code].Course.Id != id {
+					t.Errorf("课程号 %s 留下的是 %d, 期望 %d", code, got[code].Course.Id, id)
+				}
+			}
+		})
+	}
+}
+
+func TestCreditState(t *testing.T) {
+	cur := domain.Semester{Year: 2023, Term: 2}
+	withGrade := func(semester domain.Semester, total float64) domain.CourseSubscription {
+		return domain.CourseSubscription{
+			Semester: semester,
+			Grade:    &domain.SubscriptionGrade{Grade: domain.Grade{Total: total}},
+		}
+	}
+	testCases := []struct {
+		name           string
+		cs             domain.CourseSubscription
+		wantCounted    bool
+		wantInProgress bool
+	}{
+		{
+			name:        "历史学年期及格",
+			cs:          withGrade(domain.Semester{Year: 2023, Term: 1}, 75),
+			wantCounted: true,
+		},
+		{
+			name:        "正好 60 分算及格",
+			cs:          withGrade(domain.Semester{Year: 2023, Term: 1}, 60),
+			wantCounted: true,
+		},
+		{
+			name: "历史学年期挂科不算",
+			cs:   withGrade(domain.Semester{Year: 2023, Term: 1}, 59.5),
+		},
+		{
+			// 成绩接口没查到的历史课程没法判断，按修完算
+			name:        "历史学年期没有成绩",
+			cs:          domain.CourseSubscription{Semester: domain.Semester{Year: 2022, Term: 2}},
+			wantCounted: true,
+		},
+		{
+			name:           "当前学年期在修",
+			cs:             domain.CourseSubscription{Semester: cur},
+			wantCounted:    true,
+			wantInProgress: true,
+		},
+		{
+			// 在修的课成绩还没出，不能因为成绩字段判成挂科
+			name:           "当前学年期有低分也是在修",
+			cs:             withGrade(cur, 0),
+			wantCounted:    true,
+			wantInProgress: true,
+		},
+		{
+			name:           "之后的学年期在修",
+			cs:             domain.CourseSubscription{Semester: domain.Semester{Year: 2023, Term: 3}},
+			wantCounted:    true,
+			wantInProgress: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			counted, inProgress := creditState(tc.cs, cur)
+			if counted != tc.wantCounted || inProgress != tc.wantInProgress {
+				t.Errorf("creditState = (%v, %v), 期望 (%v, %v)", counted, inProgress, tc.wantCounted, tc.wantInProgress)
+			}
+		})
+	}
+}
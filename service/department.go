@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"strings"
+)
+
+var ErrInvalidDepartment = errors.New("学院不合法")
+
+type DepartmentService interface {
+	List(ctx context.Context) ([]domain.DepartmentSummary, error)
+	// Save 新建或修改学院，改名后旧名字会自动成为别名，别名只能增加
+	Save(ctx context.Context, d domain.Department) (int64, error)
+	// Merge 把重复建的学院合并到规范的学院，别名和课程一起归过去
+	Merge(ctx context.Context, fromId int64, toId int64) error
+}
+
+type departmentService struct {
+	repo repository.DepartmentRepository
+}
+
+func NewDepartmentService(repo repository.DepartmentRepository) DepartmentService {
+	return &departmentService{repo: repo}
+}
+
+func (s *departmentService) List(ctx context.Context) ([]domain.DepartmentSummary, error) {
+	return s.repo.FindAllWithCourseCount(ctx)
+}
+
+func (s *departmentService) Save(ctx context.Context, d domain.Department) (int64, error) {
+	d.Name = strings.TrimSpace(d.Name)
+	d.Code = strings.TrimSpace(d.Code)
+	if d.Name == "" {
+		return 0, ErrInvalidDepartment
+	}
+	return s.repo.Save(ctx, d)
+}
+
+func (s *departmentService) Merge(ctx context.Context, fromId int64, toId int64) error {
+	if fromId <= 0 || toId <= 0 || fromId == toId {
+		return ErrInvalidDepartment
+	}
+	return s.repo.Merge(ctx, fromId, toId)
+}
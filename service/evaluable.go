@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"time"
+)
+
+type EvaluableService interface {
+	// Check 修过这门课才能评价；选课还没结束时还可能退课，不能评价；
+	// 当前学年期的修读记录太久没有从教务系统确认过，也可能已经退课了，要先刷新一下课程列表
+	Check(ctx context.Context, uid int64, courseId int64) (domain.Evaluability, error)
+}
+
+type evaluableService struct {
+	subRepo  repository.CourseSubscriptionRepository
+	calendar CalendarService
+	// courseTTL 和 PerformanceCourseService 的一致
+	courseTTL time.Duration
+}
+
+func NewEvaluableService(subRepo repository.CourseSubscriptionRepository, calendar CalendarService,
+	courseTTL time.Duration) EvaluableService {
+	return &evaluableService{subRepo: subRepo, calendar: calendar, courseTTL: courseTTL}
+}
+
+func (s *evaluableService) Check(ctx context.Context, uid int64, courseId int64) (domain.Evaluability, error) {
+	css, err := s.subRepo.FindByUidCourseId(ctx, uid, courseId)
+	if err != nil {
+		return domain.Evaluability{}, err
+	}
+	if len(css) == 0 {
+		return domain.Evaluability{Reason: coursev1.EvaluableReason_EvaluableReasonNotSubscribed}, nil
+	}
+	cur := s.calendar.Current(ctx)
+	// 重修、刷分的时候这学期还在选课，但以前修完的那次照样可以评价，所以每一次都要看，取最近一个能评价的
+	for _, cs := range css {
+		if reason := s.reason(cs, cur); reason == coursev1.EvaluableReason_EvaluableReasonEligible {
+			return domain.Evaluability{Reason: reason, Semester: cs.Semester}, nil
+		}
+	}
+	// 都不能评价的话按最近一次给原因
+	return domain.Evaluability{Reason: s.reason(css[0], cur), Semester: css[0].Semester}, nil
+}
+
+func (s *evaluableService) reason(cs domain.CourseSubscription, cur domain.CurrentTerm) coursev1.EvaluableReason {
+	switch {
+	case cs.Semester.IsHistory(cur.Semester):
+		// 历史学年期的课是成绩接口查到的，不会再变了
+		return coursev1.EvaluableReason_EvaluableReasonEligible
+	case cs.Semester.After(cur.Semester) || cur.Selecting:
+		return coursev1.EvaluableReason_EvaluableReasonSelecting
+	case s.courseTTL >= 0 && cs.Utime.Before(time.Now().Add(-s.courseTTL)):
+		return coursev1.EvaluableReason_EvaluableReasonExpired
+	default:
+		return coursev1.EvaluableReason_EvaluableReasonEligible
+	}
+}
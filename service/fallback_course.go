@@ -18,48 +18,54 @@ var (
 // FallbackCourseService 降级装饰层
 type FallbackCourseService struct {
 	CourseService
-	repo        repository.CourseRepository
-	producer    event.Producer
-	l           logger.Logger
-	currentYear string
-	currentTerm string
-	selecting   bool // 选课中，配置文件中手动配置
+	repo     repository.CourseRepository
+	producer event.Producer
+	l        logger.Logger
+	calendar CalendarService
 }
 
 func NewFallbackCourseService(courseService CourseService, repo repository.CourseRepository,
-	producer event.Producer, l logger.Logger, currentYear string, currentTerm string, selecting bool) CourseService {
+	producer event.Producer, l logger.Logger, calendar CalendarService) CourseService {
 	return &FallbackCourseService{
 		CourseService: courseService,
 		repo:          repo,
 		producer:      producer,
 		l:             l,
-		currentYear:   currentYear,
-		currentTerm:   currentTerm,
-		selecting:     selecting,
+		calendar:      calendar,
 	}
 }
 
-func (f *FallbackCourseService) SubscriptionList(ctx context.Context, studentId string, password string, year string,
-	term string, uid ...int64) ([]domain.CourseSubscription, error) {
+func (f *FallbackCourseService) SubscriptionList(ctx context.Context, studentId string, password string,
+	semester domain.Semester, uid ...int64) ([]domain.CourseSubscription, error) {
 	// 这里为了降级，加一个装饰器
 	if len(uid) == 0 {
 		return nil, ErrUidNotInput
 	}
-	courseSubscriptions, err := f.CourseService.SubscriptionList(ctx, studentId, password, year, term)
+	courseSubscriptions, err := f.CourseService.SubscriptionList(ctx, studentId, password, semester, uid...)
 	switch {
 	case err == nil:
 		// 查询的课程不是在选课时间段的课程，开kafka异步存入数据库，这样可以保证，在数据库subscribed的课程都是可以评价的选上的课程
-		isHistory := year < f.currentYear || year == f.currentYear && term < f.currentTerm
-		isStable := isHistory || !f.selecting // 是否在课程稳定时间段，也就是确定选上了没有
+		cur := f.calendar.Current(ctx)
+		isStable := semester.IsHistory(cur.Semester) || !cur.Selecting // 是否在课程稳定时间段，也就是确定选上了没有
 		if isStable {
 			events := make([]event.CourseFromXkEvent, 0, len(courseSubscriptions))
 			for _, c := range courseSubscriptions {
-				events = append(events, event.CourseFromXkEvent{
+				evt := event.CourseFromXkEvent{
 					CourseId: c.Course.Id,
 					Uid:      uid[0],
-					Year:     c.Year,
-					Term:     c.Term,
-				})
+					Year:     c.Semester.YearStr(),
+					Term:     c.Semester.TermStr(),
+				}
+				if c.Grade != nil {
+					evt.Grade = &event.GradeInfo{
+						Regular:   c.Grade.Grade.Regular,
+						Final:     c.Grade.Grade.Final,
+						Total:     c.Grade.Grade.Total,
+						Source:    int32(c.Grade.Source),
+						FetchedAt: c.Grade.FetchedAt.UnixMilli(),
+					}
+				}
+				events = append(events, evt)
 			}
 			er := f.producer.BatchProduceCourseListEvent(ctx, events)
 			if er != nil {
@@ -69,7 +75,7 @@ func (f *FallbackCourseService) SubscriptionList(ctx context.Context, studentId
 	case ccnuv1.IsNetworkToXkError(err):
 		// 降级,从数据查旧的数据，没查到就直接返回
 		var er error
-		courseSubscriptions, er = f.CourseService.FindSubscriptionsByUidYearTermAlive(ctx, uid[0], year, term, -1)
+		courseSubscriptions, er = f.CourseService.FindSubscriptionsByUidSemesterAlive(ctx, uid[0], semester, -1)
 		if er != nil {
 			return nil, er
 		}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/ecodeclub/ekit/slice"
+	"sort"
+)
+
+// GradeService 课程成绩统计，要避免反推出单个人的成绩：
+// 1. 每个学年期的统计是快照，攒够 minSampleSize 条成绩变动才更新，人数不够一批的学年期不展示，前后两次统计的差值至少是一批人
+// 2. 对外只给取整之后的均值和比例，见 domain.GradeSummary.Publish
+type GradeService interface {
+	// GetCourseGradeStats 按学年期和按老师（同课程号、同课程名的课）分别统计
+	GetCourseGradeStats(ctx context.Context, courseId int64) (domain.CourseGradeStats, error)
+}
+
+type gradeService struct {
+	repo      repository.CourseRepository
+	gradeRepo repository.CourseGradeRepository
+	// 少于这个人数的分组不展示，同时也是统计更新的步长
+	minSampleSize int64
+}
+
+func NewGradeService(repo repository.CourseRepository, gradeRepo repository.CourseGradeRepository,
+	minSampleSize int64) GradeService {
+	return &gradeService{repo: repo, gradeRepo: gradeRepo, minSampleSize: minSampleSize}
+}
+
+func (s *gradeService) GetCourseGradeStats(ctx context.Context, courseId int64) (domain.CourseGradeStats, error) {
+	c, err := s.repo.FindById(ctx, courseId)
+	if err != nil {
+		return domain.CourseGradeStats{}, err
+	}
+	siblings, err := s.repo.FindByCodeAndName(ctx, c.CourseCode, c.Name)
+	if err != nil {
+		return domain.CourseGradeStats{}, err
+	}
+	cids := slice.Map(siblings, func(idx int, src domain.Course) int64 {
+		return src.Id
+	})
+	summaries, err := s.gradeRepo.FindSummariesByCourseIds(ctx, cids, s.minSampleSize)
+	if err != nil {
+		return domain.CourseGradeStats{}, err
+	}
+
+	var stats domain.CourseGradeStats
+	byCourse := make(map[int64]domain.GradeSummary, len(siblings))
+	for _, sm := range summaries {
+		total := byCourse[sm.CourseId]
+		total.Add(sm.Summary)
+		byCourse[sm.CourseId] = total
+		if sm.CourseId == courseId {
+			stats.Overall.Add(sm.Summary)
+			stats.BySemester = append(stats.BySemester, sm)
+		}
+	}
+	if stats.Overall.Count < s.minSampleSize {
+		// 整体都不够，分组只会更少
+		return domain.CourseGradeStats{}, nil
+	}
+
+	// 最近的学年期在前面
+	// 人数不够一批的学年期没有快照，整体就是展示出来的学年期之和，不会因为隐藏了某个学年期被反推出来
+	sort.Slice(stats.BySemester, func(i, j int) bool {
+		return stats.BySemester[i].Semester.After(stats.BySemester[j].Semester)
+	})
+
+	// 每个老师的统计也是各学年期快照之和，这里只是把没有数据的老师过滤掉
+	stats.ByTeacher = slice.FilterMap(siblings, func(idx int, src domain.Course) (domain.TeacherGradeSummary, bool) {
+		sm := byCourse[src.Id]
+		return domain.TeacherGradeSummary{
+			CourseId: src.Id,
+			Teacher:  src.Teacher,
+			Summary:  sm,
+		}, sm.Count > 0
+	})
+	return stats, nil
+}
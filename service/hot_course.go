@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/ecodeclub/ekit/slice"
+)
+
+const maxHotLimit = 50
+
+type HotCourseService interface {
+	// Top semester 为零值时取当前学年期
+	Top(ctx context.Context, semester domain.Semester, filter domain.HotCourseFilter, limit int64) ([]domain.HotCourse, error)
+	// Rebuild 按修读人数重建当前学年期的排行，纠正增量更新漏掉的部分
+	// 重建期间的增量会被覆盖掉，排行在下一次重建之前可能略少几个人，见 HotCourseCache.Replace
+	Rebuild(ctx context.Context) error
+}
+
+type hotCourseService struct {
+	repo       repository.HotCourseRepository
+	subRepo    repository.CourseSubscriptionRepository
+	courseRepo repository.CourseRepository
+	calendar   CalendarService
+}
+
+func NewHotCourseService(repo repository.HotCourseRepository, subRepo repository.CourseSubscriptionRepository,
+	courseRepo repository.CourseRepository, calendar CalendarService) HotCourseService {
+	return &hotCourseService{repo: repo, subRepo: subRepo, courseRepo: courseRepo, calendar: calendar}
+}
+
+func (s *hotCourseService) Top(ctx context.Context, semester domain.Semester, filter domain.HotCourseFilter,
+	limit int64) ([]domain.HotCourse, error) {
+	if limit <= 0 || limit > maxHotLimit {
+		limit = maxHotLimit
+	}
+	if semester.IsZero() {
+		semester = s.calendar.Current(ctx).Semester
+	}
+	hots, err := s.repo.Top(ctx, semester, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	courses, err := s.courseRepo.FindByIds(ctx, slice.Map(hots, func(idx int, src domain.HotCourse) int64 {
+		return src.Course.Id
+	}))
+	if err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]domain.Course, len(courses))
+	for _, c := range courses {
+		courseMap[c.Id] = c
+	}
+	return slice.FilterMap(hots, func(idx int, src domain.HotCourse) (domain.HotCourse, bool) {
+		c, ok := courseMap[src.Course.Id]
+		src.Course = c
+		return src, ok
+	}), nil
+}
+
+func (s *hotCourseService) Rebuild(ctx context.Context) error {
+	semester := s.calendar.Current(ctx).Semester
+	counts, err := s.subRepo.CountSubscribersBySemester(ctx, semester)
+	if err != nil {
+		return err
+	}
+	cids := make([]int64, 0, len(counts))
+	for cid := range counts {
+		cids = append(cids, cid)
+	}
+	// 要用课程的学院和课程性质分到各个排行里
+	courses, err := s.courseRepo.FindByIds(ctx, cids)
+	if err != nil {
+		return err
+	}
+	return s.repo.Replace(ctx, semester, slice.Map(courses, func(idx int, src domain.Course) domain.HotCourse {
+		return domain.HotCourse{Course: src, Count: counts[src.Id]}
+	}))
+}
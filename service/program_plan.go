@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	coursev1 "github.com/MuxiKeStack/be-api/gen/proto/course/v1"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"strings"
+)
+
+var ErrProgramPlanNotFound = repository.ErrProgramPlanNotFound
+
+// requirementStatusRank 必修课状态的好坏，显式排出来，不依赖枚举值的数值顺序
+var requirementStatusRank = map[coursev1.RequirementStatus]int{
+	coursev1.RequirementStatus_RequirementStatusUnknown:    0,
+	coursev1.RequirementStatus_RequirementStatusMissing:    1,
+	coursev1.RequirementStatus_RequirementStatusInProgress: 2,
+	coursev1.RequirementStatus_RequirementStatusSatisfied:  3,
+}
+
+type ProgramPlanService interface {
+	// Audit 用户的所有修读记录对照培养方案，培养方案由 cmd/import_plan 导入
+	Audit(ctx context.Context, uid int64, major string, entryYear int) (domain.GraduationAudit, error)
+}
+
+type programPlanService struct {
+	repo      repository.ProgramPlanRepository
+	courseSvc CourseService
+	calendar  CalendarService
+}
+
+func NewProgramPlanService(repo repository.ProgramPlanRepository, courseSvc CourseService,
+	calendar CalendarService) ProgramPlanService {
+	return &programPlanService{repo: repo, courseSvc: courseSvc, calendar: calendar}
+}
+
+func (s *programPlanService) Audit(ctx context.Context, uid int64, major string,
+	entryYear int) (domain.GraduationAudit, error) {
+	plan, err := s.repo.FindByMajorAndEntryYear(ctx, strings.TrimSpace(major), entryYear)
+	if err != nil {
+		return domain.GraduationAudit{}, err
+	}
+	css, err := s.courseSvc.FindSubscriptionsByUidSemesterAlive(ctx, uid, domain.Semester{}, -1)
+	if err != nil {
+		return domain.GraduationAudit{}, err
+	}
+	cur := s.calendar.Current(ctx).Semester
+	var (
+		total      domain.CreditTally
+		byProperty = make(map[coursev1.CourseProperty]*domain.CreditTally)
+		// 必修课按课程号对上修读记录，有多条时取最好的状态
+		byCode = make(map[string]coursev1.RequirementStatus)
+	)
+	for _, cs := range latestAttempts(css) {
+		counted, inProgress := creditState(cs, cur)
+		if !counted {
+			continue
+		}
+		total.Add(cs.Course.Credit, inProgress)
+		if byProperty[cs.Course.Property] == nil {
+			byProperty[cs.Course.Property] = &domain.CreditTally{}
+		}
+		byProperty[cs.Course.Property].Add(cs.Course.Credit, inProgress)
+		status := coursev1.RequirementStatus_RequirementStatusSatisfied
+		if inProgress {
+			status = coursev1.RequirementStatus_RequirementStatusInProgress
+		}
+		if requirementStatusRank[status] > requirementStatusRank[byCode[cs.Course.CourseCode]] {
+			byCode[cs.Course.CourseCode] = status
+		}
+	}
+	audit := domain.GraduationAudit{
+		Plan: plan,
+		Total: domain.CreditProgress{
+			MinCredits: plan.TotalCredits,
+			Earned:     total.Earned,
+			InProgress: total.InProgress,
+		},
+	}
+	for _, c := range plan.Categories {
+		p := domain.CreditProgress{MinCredits: c.MinCredits}
+		if t := byProperty[c.Property]; t != nil {
+			p.Earned, p.InProgress = t.Earned, t.InProgress
+		}
+		audit.Categories = append(audit.Categories, domain.CategoryAudit{Property: c.Property, Progress: p})
+	}
+	for _, c := range plan.RequiredCourses {
+		status, ok := byCode[c.CourseCode]
+		if !ok {
+			status = coursev1.RequirementStatus_RequirementStatusMissing
+		}
+		audit.RequiredCourses = append(audit.RequiredCourses, domain.RequiredCourseAudit{Course: c, Status: status})
+	}
+	return audit, nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/ecodeclub/ekit/slice"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	maxRelatedLimit = 20
+	// 离线任务每次处理的课程数
+	relatedBatchSize = 100
+)
+
+type RelatedCourseService interface {
+	// GetRelated 修过这门课的人也修过的课，按相似度降序
+	GetRelated(ctx context.Context, courseId int64, limit int64) ([]domain.RelatedCourse, error)
+	// Refresh 离线重新计算所有课程的相关课程
+	Refresh(ctx context.Context) error
+}
+
+// RelatedCourseConfig minCount 同时也保证了不会从推荐结果里反推出某几个人修了什么课
+type RelatedCourseConfig struct {
+	MinCount   int64
+	TopN       int
+	Expiration time.Duration
+}
+
+type relatedCourseService struct {
+	repo       repository.RelatedCourseRepository
+	subRepo    repository.CourseSubscriptionRepository
+	courseRepo repository.CourseRepository
+	cfg        RelatedCourseConfig
+	l          logger.Logger
+}
+
+func NewRelatedCourseService(repo repository.RelatedCourseRepository, subRepo repository.CourseSubscriptionRepository,
+	courseRepo repository.CourseRepository, cfg RelatedCourseConfig, l logger.Logger) RelatedCourseService {
+	return &relatedCourseService{repo: repo, subRepo: subRepo, courseRepo: courseRepo, cfg: cfg, l: l}
+}
+
+func (s *relatedCourseService) GetRelated(ctx context.Context, courseId int64, limit int64) ([]domain.RelatedCourse, error) {
+	if limit <= 0 || limit > maxRelatedLimit {
+		limit = maxRelatedLimit
+	}
+	related, err := s.repo.FindByCourseId(ctx, courseId, limit)
+	if err != nil {
+		return nil, err
+	}
+	courses, err := s.courseRepo.FindByIds(ctx, slice.Map(related, func(idx int, src domain.RelatedCourse) int64 {
+		return src.Course.Id
+	}))
+	if err != nil {
+		return nil, err
+	}
+	// FindByIds 按 ids 的顺序返回，跳过了不存在的课程，这里按 id 对回去
+	courseMap := make(map[int64]domain.Course, len(courses))
+	for _, c := range courses {
+		courseMap[c.Id] = c
+	}
+	return slice.FilterMap(related, func(idx int, src domain.RelatedCourse) (domain.RelatedCourse, bool) {
+		c, ok := courseMap[src.Course.Id]
+		src.Course = c
+		return src, ok
+	}), nil
+}
+
+func (s *relatedCourseService) Refresh(ctx context.Context) error {
+	var curId int64
+	for {
+		cids, err := s.subRepo.FindSubscribedCourseIds(ctx, curId, relatedBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(cids) == 0 {
+			return nil
+		}
+		err = s.refreshBatch(ctx, cids)
+		if err != nil {
+			return err
+		}
+		curId = cids[len(cids)-1]
+	}
+}
+
+func (s *relatedCourseService) refreshBatch(ctx context.Context, cids []int64) error {
+	cos, err := s.subRepo.FindCoSubscriptions(ctx, cids, s.cfg.MinCount)
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, 0, len(cids)+len(cos))
+	ids = append(ids, cids...)
+	for _, co := range cos {
+		ids = append(ids, co.RelatedId)
+	}
+	// 分子是按人去重的共同修读人数，分母也要按人去重，重修的人只算一次
+	counts, err := s.subRepo.CountSubscribers(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byCourse := make(map[int64][]domain.RelatedCourse, len(cids))
+	for _, co := range cos {
+		// 余弦相似度，避免热门的通识课出现在所有课的推荐里
+		norm := math.Sqrt(float64(counts[co.CourseId]) * float64(counts[co.RelatedId]))
+		if norm == 0 {
+			continue
+		}
+		byCourse[co.CourseId] = append(byCourse[co.CourseId], domain.RelatedCourse{
+			Course: domain.Course{Id: co.RelatedId},
+			Score:  float64(co.Count) / norm,
+		})
+	}
+	for _, cid := range cids {
+		related := byCourse[cid]
+		sort.Slice(related, func(i, j int) bool {
+			return related[i].Score > related[j].Score
+		})
+		if len(related) > s.cfg.TopN {
+			related = related[:s.cfg.TopN]
+		}
+		// 没有相关课程的也要写，把上一次的结果清掉
+		err = s.repo.Save(ctx, cid, related, s.cfg.Expiration)
+		if err != nil {
+			s.l.Error("保存相关课程失败", logger.Error(err), logger.Int64("courseId", cid))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/repository"
+	"sort"
+	"strings"
+)
+
+type TeacherService interface {
+	// GetProfile 老师以及他教过的所有课程，最近开过的课在前
+	GetProfile(ctx context.Context, tid int64) (domain.TeacherProfile, error)
+	FindByName(ctx context.Context, name string) (domain.Teacher, error)
+}
+
+type teacherService struct {
+	repo         repository.TeacherRepository
+	courseRepo   repository.CourseRepository
+	offeringRepo repository.CourseOfferingRepository
+}
+
+func NewTeacherService(repo repository.TeacherRepository, courseRepo repository.CourseRepository,
+	offeringRepo repository.CourseOfferingRepository) TeacherService {
+	return &teacherService{repo: repo, courseRepo: courseRepo, offeringRepo: offeringRepo}
+}
+
+func (s *teacherService) FindByName(ctx context.Context, name string) (domain.Teacher, error) {
+	return s.repo.FindByName(ctx, strings.TrimSpace(name))
+}
+
+func (s *teacherService) GetProfile(ctx context.Context, tid int64) (domain.TeacherProfile, error) {
+	t, err := s.repo.FindById(ctx, tid)
+	if err != nil {
+		return domain.TeacherProfile{}, err
+	}
+	cids, err := s.repo.FindCourseIdsByTeacherId(ctx, tid)
+	if err != nil {
+		return domain.TeacherProfile{}, err
+	}
+	courses, err := s.courseRepo.FindByIds(ctx, cids)
+	if err != nil {
+		return domain.TeacherProfile{}, err
+	}
+	offerings, err := s.offeringRepo.FindByCourseIds(ctx, cids)
+	if err != nil {
+		return domain.TeacherProfile{}, err
+	}
+	semesters := make(map[int64][]domain.Semester, len(cids))
+	for _, o := range offerings {
+		semesters[o.Course.Id] = append(semesters[o.Course.Id], o.Semester)
+	}
+	tcs := make([]domain.TeacherCourse, 0, len(courses))
+	for _, c := range courses {
+		ss := semesters[c.Id]
+		sort.Slice(ss, func(i, j int) bool {
+			return ss[i].After(ss[j])
+		})
+		tcs = append(tcs, domain.TeacherCourse{Course: c, Semesters: ss})
+	}
+	sort.SliceStable(tcs, func(i, j int) bool {
+		return latestSemester(tcs[i]).After(latestSemester(tcs[j]))
+	})
+	return domain.TeacherProfile{Teacher: t, Courses: tcs}, nil
+}
+
+// latestSemester 没有开课记录的课是零值，排在最后
+func latestSemester(tc domain.TeacherCourse) domain.Semester {
+	if len(tc.Semesters) == 0 {
+		return domain.Semester{}
+	}
+	return tc.Semesters[0]
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/MuxiKeStack/be-course/domain"
+	"github.com/MuxiKeStack/be-course/pkg/icalx"
+	"github.com/MuxiKeStack/be-course/pkg/logger"
+	"github.com/MuxiKeStack/be-course/repository"
+	"github.com/ecodeclub/ekit/slice"
+	"sort"
+	"strings"
+	"time"
+)
+
+const icsTZID = "Asia/Shanghai"
+
+// 上课时间都是北京时间，用固定时区免得依赖运行环境里的 tzdata
+var icsLocation = time.FixedZone(icsTZID, 8*60*60)
+
+type TimetableService interface {
+	// GetTimetable 用户某个学年期修读的课程以及它们的上课安排，上课安排来自用户自己的修读记录
+	GetTimetable(ctx context.Context, uid int64, semester domain.Semester) (domain.Timetable, error)
+	// ExportICS 课表导出成 iCalendar，每个上课安排是一个按周重复的日程，第几周按校历的上课开始时间算
+	ExportICS(ctx context.Context, uid int64, semester domain.Semester) ([]byte, error)
+}
+
+type timetableService struct {
+	courseSvc    CourseService
+	offeringRepo repository.CourseOfferingRepository
+	calendar     CalendarService
+	// periods 第 i 节课的上下课时间
+	periods []domain.PeriodTime
+	l       logger.Logger
+}
+
+func NewTimetableService(courseSvc CourseService, offeringRepo repository.CourseOfferingRepository,
+	calendar CalendarService, periods []domain.PeriodTime, l logger.Logger) TimetableService {
+	return &timetableService{courseSvc: courseSvc, offeringRepo: offeringRepo, calendar: calendar, periods: periods, l: l}
+}
+
+func (s *timetableService) GetTimetable(ctx context.Context, uid int64, semester domain.Semester) (domain.Timetable, error) {
+	if semester.IsZero() {
+		return domain.Timetable{}, domain.ErrInvalidSemester
+	}
+	css, err := s.courseSvc.FindSubscriptionsByUidSemesterAlive(ctx, uid, semester, -1)
+	if err != nil {
+		return domain.Timetable{}, err
+	}
+	if len(css) == 0 {
+		return domain.Timetable{Semester: semester}, nil
+	}
+	sessions, err := s.offeringRepo.FindSessions(ctx, uid, slice.Map(css, func(idx int, src domain.CourseSubscription) int64 {
+		return src.Course.Id
+	}), semester)
+	if err != nil {
+		return domain.Timetable{}, err
+	}
+	tt := domain.Timetable{Semester: semester}
+	for _, cs := range css {
+		ss, ok := sessions[cs.Course.Id]
+		if !ok {
+			tt.Unscheduled = append(tt.Unscheduled, cs.Course)
+			continue
+		}
+		for _, ms := range ss {
+			tt.Entries = append(tt.Entries, domain.TimetableEntry{Course: cs.Course, Session: ms})
+		}
+	}
+	sort.Slice(tt.Entries, func(i, j int) bool {
+		a, b := tt.Entries[i].Session, tt.Entries[j].Session
+		if a.Weekday != b.Weekday {
+			return a.Weekday < b.Weekday
+		}
+		return a.StartPeriod < b.StartPeriod
+	})
+	return tt, nil
+}
+
+func (s *timetableService) ExportICS(ctx context.Context, uid int64, semester domain.Semester) ([]byte, error) {
+	tt, err := s.GetTimetable(ctx, uid, semester)
+	if err != nil {
+		return nil, err
+	}
+	term, err := s.calendar.GetTerm(ctx, semester)
+	if err != nil {
+		return nil, err
+	}
+	cal := icalx.Calendar{
+		ProdId:   "-//MuxiKeStack//be-course//CN",
+		Name:     fmt.Sprintf("%d-%d 学年第 %d 学期课表", semester.Year, semester.Year+1, semester.Term),
+		TZID:     icsTZID,
+		Location: icsLocation,
+	}
+	var skipped []string
+	for _, e := range tt.Entries {
+		ms := e.Session
+		// 配置里没有的节次算不出上课时间，只能不导出，但要让用户知道少了哪些
+		if ms.EndPeriod > len(s.periods) || len(ms.Weeks) == 0 {
+			skipped = append(skipped, fmt.Sprintf("%s 周%d 第%d-%d节", e.Course.Name, ms.Weekday, ms.StartPeriod, ms.EndPeriod))
+			continue
+		}
+		at := func(week int, period time.Duration) time.Time {
+			return term.WeekdayDate(week, ms.Weekday, icsLocation).Add(period)
+		}
+		first, last := ms.Weeks[0], ms.Weeks[len(ms.Weeks)-1]
+		event := icalx.Event{
+			UID: fmt.Sprintf("%d-%s%s-%d-%d-%d@kstack", e.Course.Id, semester.YearStr(), semester.TermStr(),
+				ms.Weekday, ms.StartPeriod, first),
+			Summary:     e.Course.Name,
+			Location:    ms.Classroom,
+			Description: e.Course.Teacher,
+			Start:       at(first, s.periods[ms.StartPeriod-1].Start),
+			End:         at(first, s.periods[ms.EndPeriod-1].End),
+		}
+		if last > first {
+			event.Until = at(last, s.periods[ms.StartPeriod-1].Start)
+			// 单双周、跳周都用 EXDATE 把不上课的周去掉
+			week := first
+			for _, w := range ms.Weeks {
+				for ; week < w; week++ {
+					event.ExDates = append(event.ExDates, at(week, s.periods[ms.StartPeriod-1].Start))
+				}
+				week = w + 1
+			}
+		}
+		cal.Events = append(cal.Events, event)
+	}
+	if len(skipped) > 0 {
+		s.l.Warn("上课安排的节次没有配置上课时间，没有导出到日历", logger.Int64("uid", uid),
+			logger.Int("periods", len(s.periods)), logger.Any("skipped", skipped))
+		cal.Description = "以下上课安排算不出上课时间，没有导出：" + strings.Join(skipped, "；")
+	}
+	return cal.Marshal(time.Now()), nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/MuxiKeStack/be-course/repository"
 	"github.com/MuxiKeStack/be-course/repository/cache"
 	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/MuxiKeStack/be-course/service"
 	"github.com/google/wire"
 )
 
@@ -18,18 +19,42 @@ func InitApp() *App {
 		//consumer
 		ioc.InitConsumers,
 		event.NewCourseListEventConsumer,
+		// job
+		ioc.InitJobs,
+		ioc.InitRelatedCourseJob,
+		ioc.InitHotCourseJob,
 		// grpc
 		ioc.InitGRPCxKratosServer,
 		grpc.NewCourseServiceServer,
 		ioc.InitPerformanceFallBackCourseService,
+		ioc.InitCalendarService,
+		ioc.InitAdminService,
+		ioc.InitGradeService,
+		service.NewTeacherService,
+		service.NewDepartmentService,
+		ioc.InitRelatedCourseService,
+		service.NewHotCourseService,
+		service.NewProgramPlanService,
+		ioc.InitTimetableService,
+		service.NewCoursePlanService,
+		service.NewCourseFavoriteService,
+		ioc.InitEvaluableService,
 		ioc.InitProducer,
 		ioc.InitKafka,
 		repository.NewCachedCourseRepository, repository.NewCachedCourseSubscriptionRepository,
-		cache.NewRedisCourseCache, cache.NewRedisCourseSubscriptionCache,
-		dao.NewGORMCourseDAO, dao.NewGORMCourseSubscriptionDAO,
+		repository.NewCachedCalendarRepository, repository.NewCachedCourseGradeRepository,
+		repository.NewTeacherRepository, repository.NewCachedDepartmentRepository,
+		repository.NewCourseOfferingRepository, repository.NewCachedRelatedCourseRepository,
+		repository.NewCachedHotCourseRepository, repository.NewProgramPlanRepository,
+		repository.NewPlannedCourseRepository, repository.NewCachedCourseFavoriteRepository,
+		ioc.InitCourseCache, cache.NewRedisCourseSubscriptionCache, cache.NewRedisCalendarCache,
+		cache.NewRedisRelatedCourseCache, cache.NewRedisHotCourseCache, cache.NewRedisCourseFavoriteCache,
+		dao.NewGORMCourseDAO, dao.NewGORMCourseSubscriptionDAO, dao.NewGORMCalendarDAO, dao.NewGORMCourseGradeDAO, dao.NewGORMTeacherDAO, dao.NewGORMDepartmentDAO, dao.NewGORMCourseOfferingDAO,
+		dao.NewGORMProgramPlanDAO, dao.NewGORMPlannedCourseDAO, dao.NewGORMCourseFavoriteDAO,
 		ioc.InitCCNUClient,
 		// 第三方组件
 		ioc.InitRedis,
+		ioc.InitRedisClient,
 		ioc.InitEtcdClient,
 		ioc.InitDB,
 		ioc.InitLogger,
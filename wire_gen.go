@@ -13,6 +13,7 @@ import (
 	"github.com/MuxiKeStack/be-course/repository"
 	"github.com/MuxiKeStack/be-course/repository/cache"
 	"github.com/MuxiKeStack/be-course/repository/dao"
+	"github.com/MuxiKeStack/be-course/service"
 )
 
 // Injectors from wire.go:
@@ -23,22 +24,61 @@ func InitApp() *App {
 	logger := ioc.InitLogger()
 	db := ioc.InitDB(logger)
 	courseDAO := dao.NewGORMCourseDAO(db)
-	cmdable := ioc.InitRedis()
-	courseCache := cache.NewRedisCourseCache(cmdable)
-	courseRepository := repository.NewCachedCourseRepository(courseDAO, courseCache)
+	universalClient := ioc.InitRedisClient()
+	courseCache := ioc.InitCourseCache(universalClient, logger)
+	courseRepository := repository.NewCachedCourseRepository(courseDAO, courseCache, logger)
 	saramaClient := ioc.InitKafka()
 	producer := ioc.InitProducer(saramaClient)
 	courseSubscriptionDAO := dao.NewGORMCourseSubscriptionDAO(db)
+	cmdable := ioc.InitRedis(universalClient)
 	courseSubscriptionCache := cache.NewRedisCourseSubscriptionCache(cmdable)
 	courseSubscriptionRepository := repository.NewCachedCourseSubscriptionRepository(courseSubscriptionDAO, courseSubscriptionCache, logger)
-	courseService := ioc.InitPerformanceFallBackCourseService(ccnuServiceClient, courseRepository, producer, logger, courseSubscriptionRepository)
-	courseServiceServer := grpc.NewCourseServiceServer(courseService)
+	calendarDAO := dao.NewGORMCalendarDAO(db)
+	calendarCache := cache.NewRedisCalendarCache(cmdable)
+	calendarRepository := repository.NewCachedCalendarRepository(calendarDAO, calendarCache, logger)
+	calendarService := ioc.InitCalendarService(calendarRepository, logger)
+	courseOfferingDAO := dao.NewGORMCourseOfferingDAO(db)
+	courseOfferingRepository := repository.NewCourseOfferingRepository(courseOfferingDAO)
+	courseService := ioc.InitPerformanceFallBackCourseService(ccnuServiceClient, courseRepository, producer, logger, courseSubscriptionRepository, calendarService, courseOfferingRepository)
+	courseGradeDAO := dao.NewGORMCourseGradeDAO(db)
+	courseGradeRepository := repository.NewCachedCourseGradeRepository(courseGradeDAO, courseCache, logger)
+	gradeService := ioc.InitGradeService(courseRepository, courseGradeRepository)
+	teacherDAO := dao.NewGORMTeacherDAO(db)
+	teacherRepository := repository.NewTeacherRepository(teacherDAO)
+	teacherService := service.NewTeacherService(teacherRepository, courseRepository, courseOfferingRepository)
+	departmentDAO := dao.NewGORMDepartmentDAO(db)
+	departmentRepository := repository.NewCachedDepartmentRepository(departmentDAO, courseCache, logger)
+	departmentService := service.NewDepartmentService(departmentRepository)
+	relatedCourseCache := cache.NewRedisRelatedCourseCache(cmdable)
+	relatedCourseRepository := repository.NewCachedRelatedCourseRepository(relatedCourseCache)
+	relatedCourseService := ioc.InitRelatedCourseService(relatedCourseRepository, courseSubscriptionRepository, courseRepository, logger)
+	hotCourseCache := cache.NewRedisHotCourseCache(cmdable)
+	hotCourseRepository := repository.NewCachedHotCourseRepository(hotCourseCache)
+	hotCourseService := service.NewHotCourseService(hotCourseRepository, courseSubscriptionRepository, courseRepository, calendarService)
+	programPlanDAO := dao.NewGORMProgramPlanDAO(db)
+	programPlanRepository := repository.NewProgramPlanRepository(programPlanDAO)
+	programPlanService := service.NewProgramPlanService(programPlanRepository, courseService, calendarService)
+	timetableService := ioc.InitTimetableService(courseService, courseOfferingRepository, calendarService, logger)
+	plannedCourseDAO := dao.NewGORMPlannedCourseDAO(db)
+	plannedCourseRepository := repository.NewPlannedCourseRepository(plannedCourseDAO)
+	coursePlanService := service.NewCoursePlanService(plannedCourseRepository, courseRepository, courseOfferingRepository, courseService, calendarService)
+	courseFavoriteDAO := dao.NewGORMCourseFavoriteDAO(db)
+	courseFavoriteCache := cache.NewRedisCourseFavoriteCache(cmdable)
+	courseFavoriteRepository := repository.NewCachedCourseFavoriteRepository(courseFavoriteDAO, courseFavoriteCache, logger)
+	courseFavoriteService := service.NewCourseFavoriteService(courseFavoriteRepository, courseRepository)
+	evaluableService := ioc.InitEvaluableService(courseSubscriptionRepository, calendarService)
+	adminService := ioc.InitAdminService()
+	courseServiceServer := grpc.NewCourseServiceServer(courseService, calendarService, gradeService, teacherService, departmentService, relatedCourseService, hotCourseService, programPlanService, timetableService, coursePlanService, courseFavoriteService, evaluableService, adminService, logger)
 	server := ioc.InitGRPCxKratosServer(courseServiceServer, client, logger)
-	courseListEventConsumer := event.NewCourseListEventConsumer(saramaClient, logger, courseSubscriptionRepository)
+	courseListEventConsumer := event.NewCourseListEventConsumer(saramaClient, logger, courseSubscriptionRepository, courseRepository, hotCourseRepository)
 	v := ioc.InitConsumers(courseListEventConsumer)
+	relatedCourseJob := ioc.InitRelatedCourseJob(relatedCourseService, cmdable, logger)
+	hotCourseJob := ioc.InitHotCourseJob(hotCourseService, cmdable, logger)
+	v2 := ioc.InitJobs(relatedCourseJob, hotCourseJob)
 	app := &App{
 		server:    server,
 		consumers: v,
+		jobs:      v2,
 	}
 	return app
 }